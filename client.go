@@ -0,0 +1,281 @@
+package hckit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// InjectHeaders injects the necessary opentracing headers to support
+// distributed tracing, continuing the trace of the span in the request's
+// context. It is equivalent to InjectHeadersWithContext(r.Context(), r).
+func InjectHeaders(r *http.Request) {
+	InjectHeadersWithContext(r.Context(), r)
+}
+
+// InjectHeadersWithContext injects the necessary opentracing headers to support
+// distributed tracing. The client span is a child of the span in ctx, or a root
+// span when there is none, and so carries its baggage downstream. It is
+// finished before returning, so prefer TracingRoundTripper to record the
+// duration of the call.
+func InjectHeadersWithContext(ctx context.Context, r *http.Request) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+
+	span := opentracing.GlobalTracer().StartSpan(methodOperationName(r), opts...)
+	defer span.Finish()
+
+	logger().Debugf("span.Context is %v", span.Context())
+
+	span.SetTag(string(ext.HTTPUrl), truncateTag(queryPolicy{}.urlTag(r.URL), defaultMaxTagLength))
+	ext.HTTPMethod.Set(span, r.Method)
+	span.Tracer().Inject(
+		span.Context(),
+		opentracing.HTTPHeaders,
+		opentracing.HTTPHeadersCarrier(r.Header),
+	)
+}
+
+// methodOperationName is the default client span name, "HTTP <METHOD>".
+func methodOperationName(r *http.Request) string {
+	return "HTTP " + r.Method
+}
+
+// TracingRoundTripper implements the http.RoundTripper interface
+type TracingRoundTripper struct {
+	// Proxied sends the traced requests. It defaults to http.DefaultTransport.
+	Proxied http.RoundTripper
+
+	// PeerService is the logical name of the downstream service, recorded as
+	// peer.service on client spans. It defaults to the request's host name.
+	PeerService string
+
+	// OperationName returns the name of the client span for a request. It
+	// defaults to "HTTP <METHOD>", e.g. "HTTP GET".
+	OperationName func(*http.Request) string
+
+	// query controls whether the query string is included in the http.url tag.
+	query queryPolicy
+
+	// maxTagLength limits the length of string tag values. Zero uses the
+	// default and less than zero disables the limit.
+	maxTagLength int
+
+	// injectHosts, if not empty, limits header injection to matching hosts.
+	// skipHosts are never injected into, and take precedence.
+	injectHosts []string
+	skipHosts   []string
+}
+
+// ClientOption configures the TracingRoundTripper installed by WrapClient.
+type ClientOption func(*TracingRoundTripper)
+
+// WithClientOperationName sets the TracingRoundTripper's OperationName.
+func WithClientOperationName(fn func(*http.Request) string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.OperationName = fn
+	}
+}
+
+// WithClientQueryParams includes the query string in the http.url tag of client
+// spans, which is otherwise omitted. Values of the sensitive keys, matched
+// case-insensitively, are redacted.
+func WithClientQueryParams(sensitive ...string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.query.includeQuery(sensitive)
+	}
+}
+
+// WithClientAllowedQueryParams includes only the listed query parameters in the
+// http.url tag of client spans, as WithAllowedQueryParams does for server spans.
+func WithClientAllowedQueryParams(allowed ...string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.query.allowQuery(allowed)
+	}
+}
+
+// WithClientMaxTagLength truncates string tag values of client spans as
+// WithMaxTagLength does for server spans. The default is 1024; zero or less
+// disables truncation.
+func WithClientMaxTagLength(n int) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		if n <= 0 {
+			n = -1
+		}
+		trt.maxTagLength = n
+	}
+}
+
+// WithInjectHosts limits trace header injection to requests whose host matches
+// one of hosts, such as internal services, so trace IDs are not sent to third
+// parties. A host matches exactly, ignoring case and port, or, when it begins
+// with a dot, e.g. ".svc.cluster.local", also matches its subdomains. Requests
+// to other hosts are still recorded as client spans but sent without trace
+// headers. By default headers are injected for every host.
+func WithInjectHosts(hosts ...string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.injectHosts = append(trt.injectHosts, hosts...)
+	}
+}
+
+// WithoutInjectHosts never injects trace headers into requests whose host
+// matches one of hosts, as described by WithInjectHosts, e.g. for third-party
+// APIs that reject unknown headers. It takes precedence over WithInjectHosts.
+func WithoutInjectHosts(hosts ...string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.skipHosts = append(trt.skipHosts, hosts...)
+	}
+}
+
+// WithPeerService sets the TracingRoundTripper's PeerService.
+func WithPeerService(name string) ClientOption {
+	return func(trt *TracingRoundTripper) {
+		trt.PeerService = name
+	}
+}
+
+// RoundTrip traces the outbound request with a client span that is a child of
+// the span in the request's context, injecting its context, including any
+// baggage set upstream, into the headers of a copy of req. The span lasts until
+// the response is received and records its status code, or is flagged as
+// errored if the transport fails. Requests whose context comes from
+// StartRetrySpan are tagged with their attempt number.
+func (trt TracingRoundTripper) RoundTrip(req *http.Request) (res *http.Response, e error) {
+	logger().Debugf("TracingRoundTripper.RoundTrip injecting headers")
+
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(req.Context())
+
+	span := trt.startSpan(req)
+	defer span.Finish()
+
+	proxied := trt.Proxied
+	if proxied == nil {
+		proxied = http.DefaultTransport
+	}
+
+	res, e = proxied.RoundTrip(req)
+	if e != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(
+			otlog.String("event", "error"),
+			otlog.Error(e),
+		)
+		return res, e
+	}
+
+	ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+
+	return res, nil
+}
+
+// startSpan starts a client span for req as a child of the span in its
+// context, or as a root span when there is none, and injects the span context
+// into req's headers if its host allows it.
+func (trt TracingRoundTripper) startSpan(req *http.Request) opentracing.Span {
+	tracer := opentracing.GlobalTracer()
+
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+	if parent := opentracing.SpanFromContext(req.Context()); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+
+	operationName := methodOperationName
+	if trt.OperationName != nil {
+		operationName = trt.OperationName
+	}
+
+	span := tracer.StartSpan(operationName(req), opts...)
+	trt.setStringTag(span, string(ext.HTTPUrl), trt.query.urlTag(req.URL))
+	ext.HTTPMethod.Set(span, req.Method)
+
+	peerService := trt.PeerService
+	if peerService == "" {
+		peerService = req.URL.Hostname()
+	}
+	trt.setStringTag(span, string(ext.PeerService), peerService)
+	trt.setStringTag(span, string(ext.PeerAddress), req.URL.Host)
+	if attempt, ok := nextRetryAttempt(req.Context()); ok {
+		span.SetTag(tagRetryAttempt, attempt)
+	}
+
+	if !trt.injects(req.URL.Hostname()) {
+		return span
+	}
+
+	err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	if err != nil {
+		logger().Warnf("Inject failed, error received.\n%v\n", err)
+	}
+
+	return span
+}
+
+// injects reports whether trace headers are injected into requests to host.
+func (trt TracingRoundTripper) injects(host string) bool {
+	if matchHost(host, trt.skipHosts) {
+		return false
+	}
+
+	return len(trt.injectHosts) == 0 || matchHost(host, trt.injectHosts)
+}
+
+// matchHost reports whether host matches any of patterns, as described by
+// WithInjectHosts.
+func matchHost(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if h, _, err := net.SplitHostPort(pattern); err == nil {
+			pattern = h
+		}
+
+		if host == strings.TrimPrefix(pattern, ".") {
+			return true
+		}
+		if strings.HasPrefix(pattern, ".") && strings.HasSuffix(host, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WrapClient returns a copy of base whose Transport is wrapped in a
+// TracingRoundTripper configured with opts. A nil base or Transport uses
+// http.DefaultClient and http.DefaultTransport respectively.
+func WrapClient(base *http.Client, opts ...ClientOption) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+
+	client := *base
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	trt := TracingRoundTripper{Proxied: transport}
+	for _, opt := range opts {
+		opt(&trt)
+	}
+	client.Transport = trt
+
+	return &client
+}
+
+// setStringTag sets a string tag on span, truncated to the configured length.
+func (trt TracingRoundTripper) setStringTag(span opentracing.Span, key, value string) {
+	limit := trt.maxTagLength
+	if limit == 0 {
+		limit = defaultMaxTagLength
+	}
+	span.SetTag(key, truncateTag(value, limit))
+}
@@ -0,0 +1,72 @@
+package hckit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+func TestWrapClientPropagatesBaggage(t *testing.T) {
+	tests := []struct {
+		name       string
+		propagator Propagator
+		header     string
+		value      string
+	}{
+		{"b3", nil, "baggage-tenant_id", "acme"},
+		{"w3c", NewW3CPropagator(), "baggage", "tenant_id=acme"},
+		{"datadog", NewDatadogPropagator(), "ot-baggage-tenant_id", "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithLogSpans(false)}
+			if tt.propagator != nil {
+				opts = append(opts, WithPropagator(tt.propagator))
+			}
+			tracer, closer, err := NewTracer("baggage-test", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closer.Close()
+
+			old := opentracing.GlobalTracer()
+			opentracing.SetGlobalTracer(tracer)
+			defer opentracing.SetGlobalTracer(old)
+
+			var header, baggage string
+			downstream := httptest.NewServer(NewTracingMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Get(tt.header)
+				baggage = GetBaggage(r.Context(), "tenant_id")
+			})))
+			defer downstream.Close()
+
+			client := WrapClient(downstream.Client())
+			upstream := NewTracingMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				SetBaggage(r.Context(), "tenant_id", "acme")
+
+				req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+			}))
+
+			upstream.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if !strings.Contains(header, tt.value) {
+				t.Errorf("%s header = %q, want it to contain %q", tt.header, header, tt.value)
+			}
+			if baggage != "acme" {
+				t.Errorf("downstream baggage tenant_id = %q, want %q", baggage, "acme")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package hckit
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+)
+
+// clientAddress returns the address of the client that sent r. When trusted
+// is positive the address is the X-Forwarded-For entry recorded by the
+// outermost of that many trusted proxies; otherwise, or when the header is
+// absent, r.RemoteAddr is used.
+func clientAddress(r *http.Request, trusted int) string {
+	if trusted <= 0 {
+		return r.RemoteAddr
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		return r.RemoteAddr
+	}
+
+	// The outermost trusted proxy appended the address of whoever connected to
+	// it, n entries from the end. Anything to its left is unverified.
+	i := len(hops) - trusted
+	if i < 0 {
+		i = 0
+	}
+
+	return hops[i]
+}
+
+// setPeerIPTag tags span with the IP address in addr, a bare address or one
+// with a port, as peer.ipv4 or peer.ipv6. Nothing is set if addr is not an IP
+// address.
+func setPeerIPTag(span opentracing.Span, addr string) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		ap, err := netip.ParseAddrPort(addr)
+		if err != nil {
+			return
+		}
+		ip = ap.Addr()
+	}
+
+	if ip = ip.Unmap(); ip.Is4() {
+		ext.PeerHostIPv4.SetString(span, ip.String())
+	} else {
+		ext.PeerHostIPv6.Set(span, ip.String())
+	}
+}
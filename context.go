@@ -0,0 +1,167 @@
+package hckit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// SpanFromRequest returns the span stored in the request's context by
+// TracingMiddleware, and whether one was found.
+func SpanFromRequest(r *http.Request) (opentracing.Span, bool) {
+	span := opentracing.SpanFromContext(r.Context())
+	return span, span != nil
+}
+
+// StartSpanFromRequest starts a span named operationName as a child of the span
+// in the request's context, or as a root span when there is none. It returns
+// the span and a shallow copy of r whose context carries it, so sub-operations
+// started from the returned request are nested correctly. The caller must
+// finish the span.
+func StartSpanFromRequest(r *http.Request, operationName string) (opentracing.Span, *http.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), operationName)
+	return span, r.WithContext(ctx)
+}
+
+// StartFollowsFromSpan starts a span named operationName that follows from the
+// span in ctx rather than being its child, for fire-and-forget work such as
+// sending an email after the request has returned. It is a root span when ctx
+// has no span. It returns the span and a context carrying it; the caller must
+// finish the span.
+func StartFollowsFromSpan(ctx context.Context, operationName string) (opentracing.Span, context.Context) {
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.FollowsFrom(parent.Context()))
+	}
+
+	span := opentracing.GlobalTracer().StartSpan(operationName, opts...)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// DetachContext returns a context carrying the span and values of ctx, and so
+// its baggage, that is not cancelled when ctx is and has no deadline. Use it to
+// hand a request's trace to a goroutine that outlives the request, typically
+// with StartFollowsFromSpan:
+//
+//	span, bg := hckit.StartFollowsFromSpan(hckit.DetachContext(r.Context()), "send email")
+//	go func() {
+//		defer span.Finish()
+//		sendEmail(bg)
+//	}()
+func DetachContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// TraceIDFromContext returns the trace ID of the span in ctx formatted as hex,
+// for correlating log lines with traces. It returns false when ctx has no span
+// or the span was not created by a Jaeger tracer.
+func TraceIDFromContext(ctx context.Context) (string, bool) {
+	sc, ok := jaegerSpanContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	return sc.TraceID().String(), true
+}
+
+// TraceInfo identifies a span, for recording as structured log fields.
+type TraceInfo struct {
+	// TraceID and SpanID are formatted as hex.
+	TraceID string
+	SpanID  string
+	// Sampled reports whether the trace is recorded, so unsampled requests
+	// can be logged without a link to a trace that does not exist.
+	Sampled bool
+}
+
+// TraceInfoFromContext returns the TraceInfo of the span in ctx. It returns
+// false when ctx has no span or the span was not created by a Jaeger tracer.
+func TraceInfoFromContext(ctx context.Context) (TraceInfo, bool) {
+	sc, ok := jaegerSpanContext(ctx)
+	if !ok {
+		return TraceInfo{}, false
+	}
+
+	return TraceInfo{
+		TraceID: sc.TraceID().String(),
+		SpanID:  sc.SpanID().String(),
+		Sampled: sc.IsSampled(),
+	}, true
+}
+
+// ContextLogger returns a copy of base that prefixes every line with the trace
+// and span IDs of the span in ctx, e.g. "trace_id=5b1f... span_id=9c2e... ",
+// so log lines can be matched to traces. It returns base when ctx has no
+// Jaeger span.
+func ContextLogger(ctx context.Context, base *log.Logger) *log.Logger {
+	sc, ok := jaegerSpanContext(ctx)
+	if !ok {
+		return base
+	}
+
+	prefix := fmt.Sprintf("%strace_id=%s span_id=%s ", base.Prefix(), sc.TraceID(), sc.SpanID())
+	return log.New(base.Writer(), prefix, base.Flags())
+}
+
+// jaegerSpanContext returns the Jaeger span context of the span in ctx.
+func jaegerSpanContext(ctx context.Context) (jaeger.SpanContext, bool) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return jaeger.SpanContext{}, false
+	}
+
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok || !sc.IsValid() {
+		return jaeger.SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+// SetBaggage sets a baggage item on the span in ctx, propagating it to every
+// downstream span in the trace. It does nothing when ctx has no span.
+func SetBaggage(ctx context.Context, key, value string) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetBaggageItem(key, value)
+	}
+}
+
+// GetBaggage returns the baggage item key from the span in ctx, or an empty
+// string when ctx has no span or the item is not set.
+func GetBaggage(ctx context.Context, key string) string {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span.BaggageItem(key)
+	}
+
+	return ""
+}
+
+// LogError marks the span in ctx as failed and records err on it. It does
+// nothing when ctx has no span or err is nil.
+func LogError(ctx context.Context, err error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil || err == nil {
+		return
+	}
+
+	ext.Error.Set(span, true)
+	span.LogFields(
+		otlog.String("event", "error"),
+		otlog.Error(err),
+		otlog.String("message", err.Error()),
+	)
+}
+
+// SetTag sets a tag, such as order.id or cache.hit, on the span in ctx. It does
+// nothing when ctx has no span.
+func SetTag(ctx context.Context, key string, value interface{}) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag(key, value)
+	}
+}
@@ -0,0 +1,28 @@
+package hckit
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+)
+
+// dbTypeSQL is the default db.type tag of spans started by StartDBSpan.
+const dbTypeSQL = "sql"
+
+// StartDBSpan starts a client span named operation for a database call, as a
+// child of the span in ctx, tagged with statement and a db.type of "sql". Pass
+// a db.type tag in opts for other databases, e.g.
+//
+//	StartDBSpan(ctx, "GET", "GET user:1", opentracing.Tag{Key: string(ext.DBType), Value: "redis"})
+//
+// It returns the span and a context carrying it. The caller must finish the span.
+func StartDBSpan(ctx context.Context, operation, statement string, opts ...opentracing.StartSpanOption) (opentracing.Span, context.Context) {
+	opts = append([]opentracing.StartSpanOption{
+		ext.SpanKindRPCClient,
+		opentracing.Tag{Key: string(ext.DBType), Value: dbTypeSQL},
+		opentracing.Tag{Key: string(ext.DBStatement), Value: statement},
+	}, opts...)
+
+	return opentracing.StartSpanFromContext(ctx, operation, opts...)
+}
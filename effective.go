@@ -0,0 +1,123 @@
+package hckit
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"strconv"
+	"strings"
+
+	jaeger "github.com/uber/jaeger-client-go"
+	config "github.com/uber/jaeger-client-go/config"
+)
+
+// Config describes the configuration applied to the global tracer once the
+// environment, any pre-built configuration and options have been combined.
+type Config struct {
+	// ServiceName is the name spans are reported under.
+	ServiceName string
+
+	// Disabled reports whether the tracer is a no-op, in which case the
+	// reporter fields from AgentHostPort to SpanFile are empty.
+	Disabled bool
+
+	// SamplerType and SamplerParam describe the sampler for new traces, e.g.
+	// "const" and 1. For the "remote" type SamplerParam is the probability used
+	// until strategies are fetched from SamplingServerURL.
+	SamplerType       string
+	SamplerParam      float64
+	SamplingServerURL string
+
+	// OperationSampling holds the rates set by WithOperationSampling, which
+	// take precedence over the sampler for matching operations.
+	OperationSampling map[string]float64
+
+	// AgentHostPort is the jaeger-agent spans are sent to over UDP, unless
+	// CollectorEndpoint is set, in which case spans are sent there over HTTP.
+	AgentHostPort     string
+	CollectorEndpoint string
+
+	// LogSpans reports whether every reported span is also logged.
+	LogSpans bool
+
+	// SharedRPCSpan reports whether server spans share the span ID of the
+	// client span they continue.
+	SharedRPCSpan bool
+
+	// SpanFile is the file spans are also written to, if any.
+	SpanFile string
+
+	// Tags are the process tags added to every span.
+	Tags map[string]string
+
+	// OTLPProtocol is the protocol spans are exported with when the tracer was
+	// created by hckitotel.InitGlobalTracer, in which case the Jaeger fields
+	// are empty.
+	OTLPProtocol string
+}
+
+// globalConfig describes the tracer registered as globalCloser. It is guarded
+// by globalMu.
+var globalConfig Config
+
+// EffectiveConfig returns the configuration applied to the tracer registered by
+// InitGlobalTracer, InitGlobalTracerWithConfig or RegisterGlobalTracer, for
+// debugging why tracing behaves differently than expected. It returns the zero
+// Config when no global tracer is registered.
+func EffectiveConfig() Config {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	c := globalConfig
+	c.OperationSampling = maps.Clone(c.OperationSampling)
+	c.Tags = maps.Clone(c.Tags)
+
+	return c
+}
+
+// effectiveConfig returns the Config of a tracer created from cfg with tc
+// applied, filling in the Jaeger client's defaults for unset fields.
+func effectiveConfig(cfg *config.Configuration, tc *tracerConfig) Config {
+	c := Config{
+		ServiceName:       cfg.ServiceName,
+		Disabled:          cfg.Disabled,
+		SamplerType:       jaeger.SamplerTypeRemote,
+		SamplingServerURL: jaeger.DefaultSamplingServerURL,
+		OperationSampling: maps.Clone(tc.operationRates),
+		AgentHostPort:     cfg.Reporter.LocalAgentHostPort,
+		CollectorEndpoint: cfg.Reporter.CollectorEndpoint,
+		LogSpans:          cfg.Reporter.LogSpans,
+		SharedRPCSpan:     tc.sharedRPCSpan,
+		SpanFile:          tc.spanFile,
+	}
+
+	if s := cfg.Sampler; s != nil {
+		if s.Type != "" {
+			c.SamplerType = strings.ToLower(s.Type)
+		}
+		c.SamplerParam = s.Param
+		if s.SamplingServerURL != "" {
+			c.SamplingServerURL = s.SamplingServerURL
+		}
+	}
+	if c.SamplerType != jaeger.SamplerTypeRemote {
+		c.SamplingServerURL = ""
+	}
+
+	if c.Disabled {
+		c.AgentHostPort, c.CollectorEndpoint, c.LogSpans, c.SpanFile = "", "", false, ""
+	} else if c.CollectorEndpoint != "" {
+		c.AgentHostPort = ""
+	} else if c.AgentHostPort == "" {
+		c.AgentHostPort = net.JoinHostPort(jaeger.DefaultUDPSpanServerHost, strconv.Itoa(jaeger.DefaultUDPSpanServerPort))
+	}
+
+	if len(cfg.Tags) > 0 {
+		c.Tags = make(map[string]string, len(cfg.Tags))
+		for _, tag := range cfg.Tags {
+			c.Tags[tag.Key] = fmt.Sprint(tag.Value)
+		}
+	}
+
+	return c
+}
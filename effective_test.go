@@ -0,0 +1,74 @@
+package hckit
+
+import (
+	"testing"
+
+	jaeger "github.com/uber/jaeger-client-go"
+	config "github.com/uber/jaeger-client-go/config"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.Configuration
+		opts      []Option
+		wantParam float64
+		wantAgent string
+		wantFile  string
+	}{
+		{
+			name:      "default sampler",
+			wantParam: defaultSamplingProbability,
+			wantAgent: "localhost:6831",
+		},
+		{
+			name:      "remote sampler without a param",
+			cfg:       config.Configuration{Sampler: &config.SamplerConfig{Type: "Remote"}},
+			wantParam: defaultSamplingProbability,
+			wantAgent: "localhost:6831",
+		},
+		{
+			name:      "remote sampler with a param",
+			cfg:       config.Configuration{Sampler: &config.SamplerConfig{Type: jaeger.SamplerTypeRemote, Param: 0.5}},
+			wantParam: 0.5,
+			wantAgent: "localhost:6831",
+		},
+		{
+			name: "disabled",
+			opts: []Option{
+				WithDisabled(true),
+				WithAgentHostPort("agent", 6831),
+				WithLogSpans(true),
+				WithSpanFile("spans.ndjson"),
+			},
+			wantParam: defaultSamplingProbability,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			_, closer, got, err := newTracerFromConfig(&cfg, "effective-test", newTracerConfig(tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closer.Close()
+
+			if got.SamplerType != jaeger.SamplerTypeRemote {
+				t.Errorf("SamplerType = %q, want %q", got.SamplerType, jaeger.SamplerTypeRemote)
+			}
+			if got.SamplerParam != tt.wantParam {
+				t.Errorf("SamplerParam = %v, want %v", got.SamplerParam, tt.wantParam)
+			}
+			if got.AgentHostPort != tt.wantAgent {
+				t.Errorf("AgentHostPort = %q, want %q", got.AgentHostPort, tt.wantAgent)
+			}
+			if got.SpanFile != tt.wantFile {
+				t.Errorf("SpanFile = %q, want %q", got.SpanFile, tt.wantFile)
+			}
+			if got.Disabled && got.LogSpans {
+				t.Errorf("LogSpans = true for a disabled tracer")
+			}
+		})
+	}
+}
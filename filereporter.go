@@ -0,0 +1,97 @@
+package hckit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// fileSpan is the JSON form of a span written by the file reporter.
+type fileSpan struct {
+	Service       string                 `json:"service"`
+	TraceID       string                 `json:"traceID"`
+	SpanID        string                 `json:"spanID"`
+	ParentID      string                 `json:"parentID,omitempty"`
+	OperationName string                 `json:"operationName"`
+	StartTime     time.Time              `json:"startTime"`
+	DurationMs    float64                `json:"durationMs"`
+	Tags          map[string]interface{} `json:"tags,omitempty"`
+}
+
+// fileTransport is a jaeger.Transport that appends spans to a file as
+// newline-delimited JSON.
+type fileTransport struct {
+	service string
+
+	mu       sync.Mutex
+	file     *os.File
+	w        *bufio.Writer
+	buffered int
+}
+
+// newFileTransport opens path for appending, creating it if necessary.
+func newFileTransport(service, path string) (*fileTransport, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileTransport{service: service, file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Append implements jaeger.Transport.
+func (t *fileTransport) Append(span *jaeger.Span) (int, error) {
+	sc := span.SpanContext()
+	line := fileSpan{
+		Service:       t.service,
+		TraceID:       sc.TraceID().String(),
+		SpanID:        sc.SpanID().String(),
+		OperationName: span.OperationName(),
+		StartTime:     span.StartTime(),
+		DurationMs:    float64(span.Duration()) / float64(time.Millisecond),
+		Tags:          span.Tags(),
+	}
+	if sc.ParentID() != 0 {
+		line.ParentID = sc.ParentID().String()
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return 1, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.w.Write(append(b, '\n')); err != nil {
+		return 1, err
+	}
+	t.buffered++
+
+	return 0, nil
+}
+
+// Flush implements jaeger.Transport.
+func (t *fileTransport) Flush() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.buffered
+	t.buffered = 0
+
+	return n, t.w.Flush()
+}
+
+// Close implements jaeger.Transport.
+func (t *fileTransport) Close() error {
+	if _, err := t.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+
+	return t.file.Close()
+}
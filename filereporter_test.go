@@ -0,0 +1,90 @@
+package hckit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+func TestSpanFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.ndjson")
+
+	// A long flush interval leaves writing the spans to Close.
+	tracer, closer, err := NewTracer("file-test",
+		WithSpanFile(path),
+		WithSampler("const", 1),
+		WithLogSpans(false),
+		WithReporterFlushInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tracer.StartSpan("parent")
+	child := tracer.StartSpan("child", opentracing.ChildOf(parent.Context()), opentracing.Tag{Key: "component", Value: "test"})
+	child.Finish()
+	parent.Finish()
+
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 0 {
+		t.Fatalf("spans were written before Close")
+	}
+
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var spans []fileSpan
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span fileSpan
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		spans = append(spans, span)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	gotChild, gotParent := spans[0], spans[1]
+
+	if gotChild.OperationName != "child" || gotParent.OperationName != "parent" {
+		t.Errorf("operation names = %q, %q, want child, parent", gotChild.OperationName, gotParent.OperationName)
+	}
+	for _, span := range spans {
+		if span.Service != "file-test" {
+			t.Errorf("%s span service = %q, want file-test", span.OperationName, span.Service)
+		}
+		if span.TraceID == "" || span.TraceID != gotParent.TraceID {
+			t.Errorf("%s span trace ID = %q, want the parent's %q", span.OperationName, span.TraceID, gotParent.TraceID)
+		}
+		if span.StartTime.IsZero() || span.DurationMs < 0 {
+			t.Errorf("%s span timing = %v, %vms", span.OperationName, span.StartTime, span.DurationMs)
+		}
+	}
+	if gotChild.ParentID != gotParent.SpanID {
+		t.Errorf("child span parent ID = %q, want %q", gotChild.ParentID, gotParent.SpanID)
+	}
+	if gotParent.ParentID != "" {
+		t.Errorf("parent span parent ID = %q, want none", gotParent.ParentID)
+	}
+	if got := gotChild.Tags["component"]; got != "test" {
+		t.Errorf("child span component tag = %v, want test", got)
+	}
+}
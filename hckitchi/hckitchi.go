@@ -0,0 +1,50 @@
+// Package hckitchi traces requests to chi routers with hckit.
+package hckitchi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hashicorp-demoapp/go-hckit"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// Middleware returns chi middleware that traces requests as
+// hckit.NewTracingMiddleware does, naming spans after the matched chi route
+// pattern, e.g. "/articles/{id}", or the path when no route matched, unless
+// hckit.WithOperationName is given. chi only resolves the pattern while
+// routing, after middleware registered via Router.Use has started the span, so
+// the span is renamed once the handler returns.
+func Middleware(opts ...hckit.MiddlewareOption) func(http.Handler) http.Handler {
+	opts = append([]hckit.MiddlewareOption{hckit.WithOperationName(operationName), hckit.WithRoute(route)}, opts...)
+	m := hckit.NewMiddleware(opts...)
+
+	return func(next http.Handler) http.Handler {
+		return m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if span := opentracing.SpanFromContext(r.Context()); span != nil {
+				span.SetOperationName(m.OperationName(r))
+			}
+		}))
+	}
+}
+
+// operationName names requests after the chi route pattern once it has been
+// matched, falling back to the path.
+func operationName(r *http.Request) string {
+	if route := route(r); route != "" {
+		return route
+	}
+
+	return r.URL.Path
+}
+
+// route returns the chi route pattern that matched r, or "" if none has.
+func route(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+// Package hckitgin traces requests to Gin routers with hckit.
+package hckitgin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp-demoapp/go-hckit"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// spanKey is the gin.Context key holding the server span.
+const spanKey = "hckit.span"
+
+// routeKey is the request context key holding the Gin route template.
+type routeKey struct{}
+
+// Middleware returns Gin middleware that traces requests as
+// hckit.NewTracingMiddleware does. Spans are named after the matched route
+// template from c.FullPath(), e.g. "/users/:id", or the path when no route
+// matched, unless hckit.WithOperationName is given.
+//
+// The span is available to handlers from SpanFromContext and
+// c.Request.Context(). hckit's context helpers, such as hckit.SetTag, find it
+// only through c.Request.Context(), unless the engine sets
+// ContextWithFallback so that the *gin.Context itself can be passed.
+//
+// Register Gin's Recovery middleware after this one so that the recovered 500
+// is recorded along with the response. A panic reaching this middleware is
+// recorded as a 500 and re-raised.
+func Middleware(opts ...hckit.MiddlewareOption) gin.HandlerFunc {
+	opts = append([]hckit.MiddlewareOption{hckit.WithOperationName(operationName), hckit.WithRoute(route)}, opts...)
+	m := hckit.NewMiddleware(opts...)
+
+	return func(c *gin.Context) {
+		if m.Ignored(c.Request) {
+			c.Next()
+			return
+		}
+
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), routeKey{}, c.FullPath()))
+		span, r := m.Start(c.Writer, r)
+		defer func() {
+			if p := recover(); p != nil {
+				span.Finish(http.StatusInternalServerError, written(c.Writer), c.Writer.Header())
+				panic(p)
+			}
+		}()
+
+		c.Request = r
+		c.Set(spanKey, span.Span())
+		c.Next()
+
+		span.Finish(c.Writer.Status(), written(c.Writer), c.Writer.Header())
+	}
+}
+
+// SpanFromContext returns the server span started by Middleware for c, if any.
+func SpanFromContext(c *gin.Context) (opentracing.Span, bool) {
+	v, ok := c.Get(spanKey)
+	if !ok {
+		return nil, false
+	}
+	span, ok := v.(opentracing.Span)
+
+	return span, ok
+}
+
+// operationName names spans after the Gin route template, falling back to the
+// path.
+func operationName(r *http.Request) string {
+	if route := route(r); route != "" {
+		return route
+	}
+
+	return r.URL.Path
+}
+
+// route returns the Gin route template that matched r, or "" if none did.
+func route(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// written returns the body bytes written, which Gin reports as -1 when the
+// handler wrote nothing.
+func written(w gin.ResponseWriter) int64 {
+	if w.Size() < 0 {
+		return 0
+	}
+
+	return int64(w.Size())
+}
@@ -0,0 +1,359 @@
+// Package hckitgrpc traces gRPC servers and clients with hckit, continuing
+// traces across HTTP and gRPC hops.
+package hckitgrpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp-demoapp/go-hckit"
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Span tags set by the gRPC interceptors.
+const (
+	// tagGRPCCode holds the name of the RPC's final status code.
+	tagGRPCCode = "grpc.code"
+	// tagGRPCCanceled marks RPCs that were cancelled by the client.
+	tagGRPCCanceled = "grpc.canceled"
+	// tagGRPCStatusCode holds the numeric value of the RPC's final status code.
+	tagGRPCStatusCode = "rpc.grpc.status_code"
+	// tagDeadlineExceeded marks RPCs whose context passed its deadline, as
+	// the HTTP middleware does.
+	tagDeadlineExceeded = "context.deadline_exceeded"
+)
+
+// Option configures the gRPC interceptors.
+type Option func(*config)
+
+// config holds the settings used by the gRPC interceptors.
+type config struct {
+	// isError reports whether an RPC's status code marks the span as errored.
+	isError func(codes.Code) bool
+}
+
+// newConfig returns the default settings with opts applied.
+func newConfig(opts ...Option) *config {
+	c := &config{
+		isError: isServerFault,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithErrorPredicate sets the function deciding whether an RPC's status
+// code marks the span as errored, the gRPC counterpart of
+// hckit.WithErrorPredicate.
+// By default only codes indicating a server-side failure are flagged: Unknown,
+// DeadlineExceeded, Unimplemented, Internal, Unavailable and DataLoss. Codes
+// such as NotFound or InvalidArgument describe the request and are not.
+func WithErrorPredicate(fn func(codes.Code) bool) Option {
+	return func(c *config) {
+		c.isError = fn
+	}
+}
+
+// isServerFault is the default gRPC error predicate.
+func isServerFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+
+	return false
+}
+
+// metadataCarrier adapts gRPC metadata to the opentracing TextMap carrier
+// interfaces.
+type metadataCarrier metadata.MD
+
+// Set implements opentracing.TextMapWriter.
+func (c metadataCarrier) Set(key, val string) {
+	key = strings.ToLower(key)
+	c[key] = append(c[key], val)
+}
+
+// ForeachKey implements opentracing.TextMapReader.
+func (c metadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vals := range c {
+		for _, v := range vals {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that traces each
+// RPC the way hckit.TracingMiddleware traces HTTP requests. The server span
+// continues any trace found in the incoming metadata, is named after the full
+// method, and records the RPC's status code, flagging the span when the
+// handler fails.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	c := newConfig(opts...)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		span := startServerRPCSpan(ctx, info.FullMethod)
+		defer span.Finish()
+
+		resp, err := handler(opentracing.ContextWithSpan(ctx, span), req)
+		c.finishSpan(ctx, span, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor that traces
+// each stream like UnaryServerInterceptor. The span lives for the lifetime of
+// the stream and records its final status, flagging streams that fail and
+// tagging those cancelled by the client.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	c := newConfig(opts...)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span := startServerRPCSpan(ss.Context(), info.FullMethod)
+		defer span.Finish()
+
+		wrapped := &tracedServerStream{
+			ServerStream: ss,
+			ctx:          opentracing.ContextWithSpan(ss.Context(), span),
+		}
+
+		err := handler(srv, wrapped)
+		if ss.Context().Err() == context.Canceled || status.Code(err) == codes.Canceled {
+			span.SetTag(tagGRPCCanceled, true)
+		}
+		c.finishSpan(ss.Context(), span, err)
+
+		return err
+	}
+}
+
+// tracedServerStream overrides the context of a grpc.ServerStream so handlers
+// can reach the stream's span.
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context carrying the server span.
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// startServerRPCSpan starts a server span for method, continuing any trace
+// propagated in the incoming metadata of ctx.
+func startServerRPCSpan(ctx context.Context, method string) opentracing.Span {
+	tracer := opentracing.GlobalTracer()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	wireContext, err := tracer.Extract(opentracing.HTTPHeaders, metadataCarrier(md))
+	if err != nil && err != opentracing.ErrSpanContextNotFound && err != hckit.ErrNotSampled {
+		hckit.CurrentLogger().Warnf("Extract failed, error received.\n%v\n", err)
+	}
+	if err != nil {
+		// Not every tracer returns a nil context with the error.
+		wireContext = nil
+	}
+
+	span := tracer.StartSpan(method, ext.RPCServerOption(wireContext))
+	ext.Component.Set(span, "gRPC")
+	if err == hckit.ErrNotSampled {
+		ext.SamplingPriority.Set(span, 0)
+	}
+
+	return span
+}
+
+// finishSpan records the outcome of an RPC made with ctx on span, flagging it
+// as errored according to the configured predicate.
+func (c *config) finishSpan(ctx context.Context, span opentracing.Span, err error) {
+	code := rpcCode(err)
+	span.SetTag(tagGRPCCode, code.String())
+	span.SetTag(tagGRPCStatusCode, uint32(code))
+
+	if c.isError(code) {
+		ext.Error.Set(span, true)
+	}
+	setDeadlineTag(span, ctx)
+	if err != nil {
+		span.LogFields(otlog.Error(err))
+	}
+}
+
+// rpcCode returns the status code of an RPC that ended with err, mapping
+// context errors to Canceled and DeadlineExceeded.
+func rpcCode(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+
+	return status.FromContextError(err).Code()
+}
+
+// UnaryClientInterceptor returns a grpc.UnaryClientInterceptor that traces each
+// outbound RPC. The client span is a child of the span in the call's context,
+// its context is injected into the outgoing metadata, and it finishes with the
+// RPC's status once the call returns.
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	c := newConfig(opts...)
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := startClientRPCSpan(ctx, method)
+		defer span.Finish()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		c.finishSpan(ctx, span, err)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a grpc.StreamClientInterceptor that traces
+// each outbound stream like UnaryClientInterceptor. The client span stays open
+// until the stream ends, fails, or its context is done. Register it alongside
+// UnaryClientInterceptor with grpc.WithStreamInterceptor.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	c := newConfig(opts...)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientRPCSpan(ctx, method)
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			c.finishSpan(ctx, span, err)
+			span.Finish()
+			return cs, err
+		}
+
+		s := &tracedClientStream{
+			ClientStream: cs,
+			ctx:          ctx,
+			desc:         desc,
+			span:         span,
+			config:       c,
+			done:         make(chan struct{}),
+		}
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.finish(ctx.Err())
+			case <-s.done:
+			}
+		}()
+
+		return s, nil
+	}
+}
+
+// tracedClientStream finishes its span when the wrapped grpc.ClientStream ends.
+type tracedClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	desc   *grpc.StreamDesc
+	span   opentracing.Span
+	config *config
+
+	once sync.Once
+	done chan struct{}
+}
+
+// finish records err and finishes the span. Only the first call has an effect.
+func (s *tracedClientStream) finish(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		if err == context.Canceled || status.Code(err) == codes.Canceled {
+			s.span.SetTag(tagGRPCCanceled, true)
+		}
+		s.config.finishSpan(s.ctx, s.span, err)
+		s.span.Finish()
+	})
+}
+
+// Header finishes the span if the stream failed before headers arrived.
+func (s *tracedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish(err)
+	}
+
+	return md, err
+}
+
+// SendMsg finishes the span if the message could not be sent.
+func (s *tracedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		s.finish(err)
+	}
+
+	return err
+}
+
+// RecvMsg finishes the span when the stream completes or fails.
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	case !s.desc.ServerStreams:
+		// A stream without server streaming receives a single response.
+		s.finish(nil)
+	}
+
+	return err
+}
+
+// startClientRPCSpan starts a client span for method as a child of the span in
+// ctx, returning a context that carries the span and its propagated context in
+// the outgoing metadata.
+func startClientRPCSpan(ctx context.Context, method string) (opentracing.Span, context.Context) {
+	tracer := opentracing.GlobalTracer()
+
+	opts := []opentracing.StartSpanOption{ext.SpanKindRPCClient}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+
+	span := tracer.StartSpan(method, opts...)
+	ext.Component.Set(span, "gRPC")
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, metadataCarrier(md)); err != nil {
+		hckit.CurrentLogger().Warnf("Inject failed, error received.\n%v\n", err)
+	}
+
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// setDeadlineTag tags span with context.deadline_exceeded when ctx has passed
+// its deadline, separating timeouts from other failures.
+func setDeadlineTag(span opentracing.Span, ctx context.Context) {
+	if ctx.Err() == context.DeadlineExceeded {
+		span.SetTag(tagDeadlineExceeded, true)
+	}
+}
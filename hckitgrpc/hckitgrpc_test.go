@@ -0,0 +1,311 @@
+package hckitgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testServer implements the RPCs used by the tests, failing with the status
+// requested by the client.
+type testServer struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (testServer) UnaryCall(ctx context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	if err := requestedStatus(req.GetResponseStatus()); err != nil {
+		return nil, err
+	}
+
+	return &testpb.SimpleResponse{}, nil
+}
+
+func (testServer) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	for range req.GetResponseParameters() {
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{}); err != nil {
+			return err
+		}
+	}
+
+	return requestedStatus(req.GetResponseStatus())
+}
+
+func (testServer) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{})
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (testServer) FullDuplexCall(stream testpb.TestService_FullDuplexCallServer) error {
+	// Let the client know the stream is being handled before it cancels.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+// requestedStatus returns the error for the status a client asked for, or nil.
+func requestedStatus(s *testpb.EchoStatus) error {
+	if s == nil {
+		return nil
+	}
+
+	return status.Error(codes.Code(s.GetCode()), s.GetMessage())
+}
+
+// newTestClient serves testServer over an in-memory connection with the
+// server interceptors, and returns a client using the client interceptors.
+// Spans are recorded by the returned tracer, registered as the GlobalTracer
+// for the rest of the test.
+func newTestClient(t *testing.T) (testpb.TestServiceClient, *mocktracer.MockTracer) {
+	tracer := mocktracer.New()
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(old) })
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor()),
+		grpc.StreamInterceptor(StreamServerInterceptor()),
+	)
+	testpb.RegisterTestServiceServer(srv, testServer{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return testpb.NewTestServiceClient(conn), tracer
+}
+
+// waitForSpans returns the spans finished by tracer once there are n of them,
+// failing the test if that takes too long.
+func waitForSpans(t *testing.T, tracer *mocktracer.MockTracer, n int) []*mocktracer.MockSpan {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		spans := tracer.FinishedSpans()
+		if len(spans) >= n || time.Now().After(deadline) {
+			if len(spans) != n {
+				t.Fatalf("got %d spans, want %d", len(spans), n)
+			}
+			return spans
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// spanOfKind returns the span of the given kind among spans.
+func spanOfKind(t *testing.T, spans []*mocktracer.MockSpan, kind ext.SpanKindEnum) *mocktracer.MockSpan {
+	t.Helper()
+
+	for _, span := range spans {
+		if span.Tag(string(ext.SpanKind)) == kind {
+			return span
+		}
+	}
+	t.Fatalf("no %s span in %v", kind, spans)
+
+	return nil
+}
+
+// assertRPCSpans checks that the client span is a child of parent, that the
+// server span continues it, and that both record code.
+func assertRPCSpans(t *testing.T, spans []*mocktracer.MockSpan, parent opentracing.Span, method string, code codes.Code, errored bool) {
+	t.Helper()
+
+	client := spanOfKind(t, spans, ext.SpanKindRPCClientEnum)
+	server := spanOfKind(t, spans, ext.SpanKindRPCServerEnum)
+
+	if want := parent.(*mocktracer.MockSpan).SpanContext.SpanID; client.ParentID != want {
+		t.Errorf("client span parent = %d, want %d", client.ParentID, want)
+	}
+	if server.ParentID != client.SpanContext.SpanID {
+		t.Errorf("server span parent = %d, want the client span %d", server.ParentID, client.SpanContext.SpanID)
+	}
+
+	for _, span := range []*mocktracer.MockSpan{client, server} {
+		if span.OperationName != method {
+			t.Errorf("%v span name = %q, want %q", span.Tag(string(ext.SpanKind)), span.OperationName, method)
+		}
+		if got := span.Tag(tagGRPCCode); got != code.String() {
+			t.Errorf("%v span %s = %v, want %q", span.Tag(string(ext.SpanKind)), tagGRPCCode, got, code.String())
+		}
+		if got := span.Tag(tagGRPCStatusCode); got != uint32(code) {
+			t.Errorf("%v span %s = %v, want %d", span.Tag(string(ext.SpanKind)), tagGRPCStatusCode, got, code)
+		}
+		if got, _ := span.Tag(string(ext.Error)).(bool); got != errored {
+			t.Errorf("%v span error = %v, want %v", span.Tag(string(ext.SpanKind)), got, errored)
+		}
+	}
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  *testpb.EchoStatus
+		code    codes.Code
+		errored bool
+	}{
+		{"ok", nil, codes.OK, false},
+		{"client fault", &testpb.EchoStatus{Code: int32(codes.NotFound), Message: "missing"}, codes.NotFound, false},
+		{"server fault", &testpb.EchoStatus{Code: int32(codes.Internal), Message: "broken"}, codes.Internal, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, tracer := newTestClient(t)
+
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			_, err := client.UnaryCall(ctx, &testpb.SimpleRequest{ResponseStatus: tt.status})
+			if status.Code(err) != tt.code {
+				t.Fatalf("UnaryCall() error = %v, want code %s", err, tt.code)
+			}
+
+			spans := waitForSpans(t, tracer, 2)
+			assertRPCSpans(t, spans, parent, "/grpc.testing.TestService/UnaryCall", tt.code, tt.errored)
+		})
+	}
+}
+
+func TestStreamInterceptorsServerStreaming(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  *testpb.EchoStatus
+		code    codes.Code
+		errored bool
+	}{
+		{"eof", nil, codes.OK, false},
+		{"error", &testpb.EchoStatus{Code: int32(codes.Unavailable), Message: "draining"}, codes.Unavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, tracer := newTestClient(t)
+
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			stream, err := client.StreamingOutputCall(ctx, &testpb.StreamingOutputCallRequest{
+				ResponseParameters: []*testpb.ResponseParameters{{}, {}},
+				ResponseStatus:     tt.status,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+				if spans := tracer.FinishedSpans(); len(spans) > 1 {
+					t.Fatalf("client span finished before the stream ended")
+				}
+			}
+			if tt.status == nil {
+				if err != io.EOF {
+					t.Fatalf("Recv() error = %v, want io.EOF", err)
+				}
+			} else if status.Code(err) != tt.code {
+				t.Fatalf("Recv() error = %v, want code %s", err, tt.code)
+			}
+
+			spans := waitForSpans(t, tracer, 2)
+			assertRPCSpans(t, spans, parent, "/grpc.testing.TestService/StreamingOutputCall", tt.code, tt.errored)
+		})
+	}
+}
+
+func TestStreamClientInterceptorFinishesOnSingleResponse(t *testing.T) {
+	client, tracer := newTestClient(t)
+
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	stream, err := client.StreamingInputCall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&testpb.StreamingInputCallRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	// The stream has no server streaming, so receiving its single response
+	// ends it without waiting for io.EOF or the context.
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := waitForSpans(t, tracer, 2)
+	assertRPCSpans(t, spans, parent, "/grpc.testing.TestService/StreamingInputCall", codes.OK, false)
+}
+
+func TestStreamInterceptorsCanceled(t *testing.T) {
+	client, tracer := newTestClient(t)
+
+	parent := tracer.StartSpan("parent")
+	ctx, cancel := context.WithCancel(opentracing.ContextWithSpan(context.Background(), parent))
+
+	stream, err := client.FullDuplexCall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	spans := waitForSpans(t, tracer, 2)
+	assertRPCSpans(t, spans, parent, "/grpc.testing.TestService/FullDuplexCall", codes.Canceled, false)
+	for _, span := range spans {
+		if got := span.Tag(tagGRPCCanceled); got != true {
+			t.Errorf("%v span %s = %v, want true", span.Tag(string(ext.SpanKind)), tagGRPCCanceled, got)
+		}
+	}
+}
+
+func TestRPCCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{nil, codes.OK},
+		{status.Error(codes.NotFound, "missing"), codes.NotFound},
+		{context.Canceled, codes.Canceled},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{errors.New("unexpected"), codes.Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := rpcCode(tt.err); got != tt.want {
+			t.Errorf("rpcCode(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
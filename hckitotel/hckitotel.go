@@ -0,0 +1,170 @@
+// Package hckitotel backs hckit's OpenTracing instrumentation with an
+// OpenTelemetry TracerProvider that exports spans over OTLP.
+package hckitotel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp-demoapp/go-hckit"
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// instrumentationName identifies hckit as the source of OpenTelemetry spans.
+const instrumentationName = "github.com/hashicorp-demoapp/go-hckit"
+
+// OTLP protocols supported by InitGlobalTracer.
+const (
+	OTLPProtocolGRPC         = "grpc"
+	OTLPProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// Option configures the tracer created by InitGlobalTracer.
+type Option func(*config)
+
+// config holds the settings used by InitGlobalTracer.
+type config struct {
+	protocol string
+}
+
+// newConfig returns the default settings with opts applied. The protocol
+// defaults to OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL,
+// falling back to gRPC.
+func newConfig(opts ...Option) *config {
+	c := &config{
+		protocol: OTLPProtocolGRPC,
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" {
+		c.protocol = p
+	}
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); p != "" {
+		c.protocol = p
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithOTLPProtocol sets the protocol used to export spans, either
+// OTLPProtocolGRPC or OTLPProtocolHTTPProtobuf. It takes precedence over the
+// OTEL_EXPORTER_OTLP_PROTOCOL environment variables.
+func WithOTLPProtocol(protocol string) Option {
+	return func(c *config) {
+		c.protocol = protocol
+	}
+}
+
+// newOTLPExporter creates an exporter for the configured protocol. Endpoints,
+// headers and TLS settings are read from the OTEL_EXPORTER_OTLP_* environment
+// variables by the exporter itself.
+func newOTLPExporter(ctx context.Context, protocol string) (sdktrace.SpanExporter, error) {
+	switch protocol {
+	case OTLPProtocolGRPC:
+		return otlptracegrpc.New(ctx)
+	case OTLPProtocolHTTPProtobuf:
+		return otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", protocol)
+	}
+}
+
+// InitGlobalTracer sets the GlobalTracer to an OpenTracing bridge backed by an
+// OpenTelemetry TracerProvider that exports spans over OTLP gRPC or HTTP, so
+// existing hckit.TracingMiddleware and hckit.InjectHeaders instrumentation
+// keeps working while services migrate. The exporter and resource are
+// configured from the standard OTEL_* environment variables, with service,
+// when not empty, replacing OTEL_SERVICE_NAME; hckit.ErrEmptyServiceName is
+// returned when both are empty. Context is propagated using W3C Trace Context,
+// W3C Baggage and Zipkin B3 headers, so traces still join with services using
+// hckit.InitGlobalTracer.
+//
+// Closing the returned io.Closer shuts down the TracerProvider, flushing any
+// buffered spans. As with hckit.InitGlobalTracer, later calls return the
+// existing closer until it is closed.
+func InitGlobalTracer(service string, opts ...Option) (io.Closer, error) {
+	return hckit.RegisterGlobalTracer(func() (opentracing.Tracer, io.Closer, hckit.Config, error) {
+		return newTracer(service, newConfig(opts...))
+	})
+}
+
+// newTracer creates the OpenTracing bridge for service, registering its
+// TracerProvider and propagator with otel, and returns it with the
+// configuration applied.
+func newTracer(service string, oc *config) (opentracing.Tracer, io.Closer, hckit.Config, error) {
+	if service == "" && os.Getenv("OTEL_SERVICE_NAME") == "" {
+		return nil, nil, hckit.Config{}, hckit.ErrEmptyServiceName
+	}
+
+	ctx := context.Background()
+
+	exporter, err := newOTLPExporter(ctx, oc.protocol)
+	if err != nil {
+		hckit.CurrentLogger().Errorf("Could not initialize OTLP exporter: %s", err.Error())
+		return nil, nil, hckit.Config{}, err
+	}
+
+	resourceOpts := []resource.Option{
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithSchemaURL(semconv.SchemaURL),
+	}
+	if service != "" {
+		resourceOpts = append(resourceOpts, resource.WithAttributes(semconv.ServiceName(service)))
+	}
+	res, err := resource.New(ctx, resourceOpts...)
+	if err != nil {
+		hckit.CurrentLogger().Errorf("Could not initialize OpenTelemetry resource: %s", err.Error())
+		return nil, nil, hckit.Config{}, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	propagator := propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)),
+	)
+
+	bridge, wrapper := otbridge.NewTracerPair(provider.Tracer(instrumentationName))
+	bridge.SetTextMapPropagator(propagator)
+
+	otel.SetTracerProvider(wrapper)
+	otel.SetTextMapPropagator(propagator)
+
+	serviceName, _ := res.Set().Value(semconv.ServiceNameKey)
+	effective := hckit.Config{ServiceName: serviceName.AsString(), OTLPProtocol: oc.protocol}
+
+	return bridge, providerCloser{provider}, effective, nil
+}
+
+// providerCloser adapts an OpenTelemetry TracerProvider to io.Closer.
+type providerCloser struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Close shuts down the TracerProvider, flushing any buffered spans.
+func (c providerCloser) Close() error {
+	return c.Shutdown(context.Background())
+}
+
+// Shutdown shuts down the TracerProvider, giving up once ctx is done.
+func (c providerCloser) Shutdown(ctx context.Context) error {
+	return c.provider.Shutdown(ctx)
+}
@@ -0,0 +1,90 @@
+// Package hckitprom records the RED metrics of hckit's tracing middleware with
+// Prometheus.
+package hckitprom
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp-demoapp/go-hckit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the rate, errors and duration of traced requests as
+// http_server_requests_total and http_server_errors_total counters and an
+// http_server_request_duration_seconds histogram, labeled by operation and
+// status code.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var _ hckit.Metrics = (*Metrics)(nil)
+
+// labels are the labels of every RED metric.
+var labels = []string{"operation", "code"}
+
+// NewMetrics creates the RED metrics and registers them with reg, reusing any
+// already registered by another middleware, so several middlewares may share
+// reg. Pass the result to hckit.WithMetrics.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_requests_total",
+			Help: "Number of HTTP requests handled, by operation and status code.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_errors_total",
+			Help: "Number of HTTP requests whose spans were flagged as errors, by operation and status code.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_server_request_duration_seconds",
+			Help:    "Duration of HTTP requests, by operation and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+	}
+
+	var err error
+	if m.requests, err = register(reg, m.requests); err != nil {
+		return nil, err
+	}
+	if m.errors, err = register(reg, m.errors); err != nil {
+		return nil, err
+	}
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// register registers c with reg and returns it, or the equivalent collector
+// registered earlier.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+
+	return c, err
+}
+
+// ObserveRequest records a request to operation that completed with status
+// after duration.
+func (m *Metrics) ObserveRequest(operation string, status int, errored bool, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(operation, code).Inc()
+	if errored {
+		m.errors.WithLabelValues(operation, code).Inc()
+	}
+	m.duration.WithLabelValues(operation, code).Observe(duration.Seconds())
+}
@@ -0,0 +1,92 @@
+package hckittest
+
+import (
+	"fmt"
+	"testing"
+
+	ext "github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// FindSpan returns the first span named operationName, or nil if there is none.
+func FindSpan(spans []*mocktracer.MockSpan, operationName string) *mocktracer.MockSpan {
+	for _, span := range spans {
+		if span.OperationName == operationName {
+			return span
+		}
+	}
+
+	return nil
+}
+
+// AssertSpanExists fails the test unless spans contains a span named
+// operationName, and returns the first such span.
+func AssertSpanExists(t testing.TB, spans []*mocktracer.MockSpan, operationName string) *mocktracer.MockSpan {
+	t.Helper()
+
+	span := FindSpan(spans, operationName)
+	if span == nil {
+		names := make([]string, len(spans))
+		for i, s := range spans {
+			names[i] = s.OperationName
+		}
+		t.Fatalf("no span named %q, got %q", operationName, names)
+	}
+
+	return span
+}
+
+// AssertSpanTag fails the test unless span has the tag key set to value. Values
+// are compared by their formatted form, so AssertSpanTag(t, span,
+// "http.status_code", 500) matches the uint16 set by the middleware.
+func AssertSpanTag(t testing.TB, span *mocktracer.MockSpan, key string, value interface{}) {
+	t.Helper()
+
+	got := span.Tag(key)
+	if got == nil {
+		t.Errorf("span %q has no tag %q", span.OperationName, key)
+		return
+	}
+	if fmt.Sprint(got) != fmt.Sprint(value) {
+		t.Errorf("span %q tag %q = %v, want %v", span.OperationName, key, got, value)
+	}
+}
+
+// AssertStatusCode fails the test unless span's http.status_code tag is code.
+func AssertStatusCode(t testing.TB, span *mocktracer.MockSpan, code int) {
+	t.Helper()
+	AssertSpanTag(t, span, string(ext.HTTPStatusCode), code)
+}
+
+// AssertURL fails the test unless span's http.url tag is url.
+func AssertURL(t testing.TB, span *mocktracer.MockSpan, url string) {
+	t.Helper()
+	AssertSpanTag(t, span, string(ext.HTTPUrl), url)
+}
+
+// AssertSpanError fails the test unless span's error flag matches want. A span
+// without the error tag is treated as not errored.
+func AssertSpanError(t testing.TB, span *mocktracer.MockSpan, want bool) {
+	t.Helper()
+
+	got, _ := span.Tag(string(ext.Error)).(bool)
+	if got != want {
+		t.Errorf("span %q error = %t, want %t", span.OperationName, got, want)
+	}
+}
+
+// AssertBaggage fails the test unless span carries the baggage item key set
+// to value, e.g. to check that baggage set upstream reached a server span
+// after an outbound call.
+func AssertBaggage(t testing.TB, span *mocktracer.MockSpan, key, value string) {
+	t.Helper()
+
+	got, ok := span.SpanContext.Baggage[key]
+	if !ok {
+		t.Errorf("span %q has no baggage item %q", span.OperationName, key)
+		return
+	}
+	if got != value {
+		t.Errorf("span %q baggage item %q = %q, want %q", span.OperationName, key, got, value)
+	}
+}
@@ -0,0 +1,23 @@
+// Package hckittest provides helpers for testing code instrumented with hckit
+// without a running Jaeger.
+package hckittest
+
+import (
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// RecordSpans installs an in-memory tracer as the GlobalTracer, runs fn, and
+// returns the spans finished while it ran. The previous GlobalTracer is
+// restored afterwards, so tests using RecordSpans must not run in parallel.
+func RecordSpans(fn func()) []*mocktracer.MockSpan {
+	tracer := mocktracer.New()
+
+	prev := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(prev)
+
+	fn()
+
+	return tracer.FinishedSpans()
+}
@@ -0,0 +1,34 @@
+package hckit
+
+import (
+	"context"
+	"fmt"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// HCLogger adapts an hclog.Logger to Logger. Pass it to WithLogger to route
+// both the package's messages and the Jaeger client's through hclog.
+func HCLogger(l hclog.Logger) Logger {
+	return hcLogger{l}
+}
+
+type hcLogger struct {
+	l hclog.Logger
+}
+
+func (h hcLogger) Debugf(format string, args ...interface{}) { h.l.Debug(fmt.Sprintf(format, args...)) }
+func (h hcLogger) Infof(format string, args ...interface{})  { h.l.Info(fmt.Sprintf(format, args...)) }
+func (h hcLogger) Warnf(format string, args ...interface{})  { h.l.Warn(fmt.Sprintf(format, args...)) }
+func (h hcLogger) Errorf(format string, args ...interface{}) { h.l.Error(fmt.Sprintf(format, args...)) }
+
+// ContextHCLogger returns a copy of base with trace_id and span_id fields set
+// from the span in ctx, as ContextLogger does for the standard library logger.
+func ContextHCLogger(ctx context.Context, base hclog.Logger) hclog.Logger {
+	sc, ok := jaegerSpanContext(ctx)
+	if !ok {
+		return base
+	}
+
+	return base.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
@@ -0,0 +1,79 @@
+package hckit
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+)
+
+// Span tags set on Kafka producer and consumer spans.
+const (
+	tagMessagingSystem      = "messaging.system"
+	tagMessagingDestination = "messaging.destination"
+	messagingSystemKafka    = "kafka"
+)
+
+// StartProducerSpan starts a producer span for publishing a message to topic and
+// injects its context into headers so that consumers can continue the trace.
+// The span follows from the span in ctx, since publishing does not block the
+// caller on delivery, or is a root span when ctx carries none. Kafka client
+// libraries each have their own header type, so copy the headers across after
+// the call, e.g.
+//
+//	headers := opentracing.TextMapCarrier{}
+//	span, ctx := hckit.StartProducerSpan(ctx, "orders", headers)
+//	defer span.Finish()
+//	for k, v := range headers {
+//		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+//	}
+//
+// It returns the span and a context carrying it. The caller must finish the span.
+func StartProducerSpan(ctx context.Context, topic string, headers opentracing.TextMapWriter) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindProducer}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.FollowsFrom(parent.Context()))
+	}
+
+	span := opentracing.GlobalTracer().StartSpan("produce "+topic, opts...)
+	setMessagingTags(span, topic)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	if err := InjectTextMap(ctx, headers); err != nil {
+		logger().Warnf("Inject failed, error received.\n%v\n", err)
+	}
+
+	return span, ctx
+}
+
+// StartConsumerSpan starts a consumer span for processing a message received
+// from topic. The span is a child of the producer span whose context was
+// injected into headers, so that each message is processed under the span that
+// published it. When headers carry no span context the span is a child of the
+// span in ctx, or a root span.
+//
+// It returns the span and a context carrying it. The caller must finish the span.
+func StartConsumerSpan(ctx context.Context, topic string, headers opentracing.TextMapReader) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{ext.SpanKindConsumer}
+	if producer, err := ExtractTextMap(headers); err == nil {
+		opts = append(opts, opentracing.ChildOf(producer))
+	} else {
+		if err != opentracing.ErrSpanContextNotFound && err != ErrNotSampled {
+			logger().Warnf("Extract failed, error received.\n%v\n", err)
+		}
+		if parent := opentracing.SpanFromContext(ctx); parent != nil {
+			opts = append(opts, opentracing.ChildOf(parent.Context()))
+		}
+	}
+
+	span := opentracing.GlobalTracer().StartSpan("consume "+topic, opts...)
+	setMessagingTags(span, topic)
+
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// setMessagingTags tags span with the Kafka messaging system and topic.
+func setMessagingTags(span opentracing.Span, topic string) {
+	span.SetTag(tagMessagingSystem, messagingSystemKafka)
+	span.SetTag(tagMessagingDestination, topic)
+}
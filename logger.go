@@ -0,0 +1,179 @@
+package hckit
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// LogLevel controls which of the package's messages are logged.
+type LogLevel int32
+
+// Log levels in increasing order of verbosity.
+const (
+	LevelOff LogLevel = iota
+	LevelError
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+// logLevelEnv names the environment variable that sets the initial LogLevel,
+// one of off, error, warn, info, or debug.
+const logLevelEnv = "HCKIT_LOG_LEVEL"
+
+// Logger is the interface used for the package's internal logging.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// StdLogger is a Logger that writes to the standard log package, prefixing
+// each message with its level.
+var StdLogger Logger = stdLogger{}
+
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...interface{}) { log.Printf("DEBUG: "+format, args...) }
+func (stdLogger) Infof(format string, args ...interface{})  { log.Printf("INFO: "+format, args...) }
+func (stdLogger) Warnf(format string, args ...interface{})  { log.Printf("WARN: "+format, args...) }
+func (stdLogger) Errorf(format string, args ...interface{}) { log.Printf("ERROR: "+format, args...) }
+
+// loggerHolder wraps a Logger so implementations of different concrete types
+// can be stored in the same atomic.Value.
+type loggerHolder struct {
+	Logger
+}
+
+var (
+	pkgLogger atomic.Value
+	pkgLevel  = int32(LevelInfo)
+)
+
+func init() {
+	pkgLogger.Store(loggerHolder{StdLogger})
+
+	if level, ok := parseLogLevel(os.Getenv(logLevelEnv)); ok {
+		SetLogLevel(level)
+	}
+}
+
+// parseLogLevel returns the LogLevel named by s.
+func parseLogLevel(s string) (LogLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "off":
+		return LevelOff, true
+	case "error":
+		return LevelError, true
+	case "warn":
+		return LevelWarn, true
+	case "info":
+		return LevelInfo, true
+	case "debug":
+		return LevelDebug, true
+	}
+
+	return 0, false
+}
+
+// SetLogger sets the Logger used by the middleware and HTTP client helpers.
+// It defaults to StdLogger.
+func SetLogger(l Logger) {
+	pkgLogger.Store(loggerHolder{l})
+}
+
+// SetLogLevel sets the most verbose level that is logged. It defaults to
+// LevelInfo, or the value of HCKIT_LOG_LEVEL when set.
+func SetLogLevel(level LogLevel) {
+	atomic.StoreInt32(&pkgLevel, int32(level))
+}
+
+// CurrentLogger returns the Logger set by SetLogger, filtered by the level set
+// by SetLogLevel, for packages extending hckit such as hckitgrpc.
+func CurrentLogger() Logger {
+	return logger()
+}
+
+// logger returns the Logger set by SetLogger, filtered by the level set by
+// SetLogLevel.
+func logger() Logger {
+	return levelLogger{baseLogger(), currentLogLevel()}
+}
+
+// baseLogger returns the Logger set by SetLogger without level filtering.
+func baseLogger() Logger {
+	return pkgLogger.Load().(loggerHolder).Logger
+}
+
+// currentLogLevel returns the level set by SetLogLevel.
+func currentLogLevel() LogLevel {
+	return LogLevel(atomic.LoadInt32(&pkgLevel))
+}
+
+// levelLogger discards messages more verbose than level.
+type levelLogger struct {
+	l     Logger
+	level LogLevel
+}
+
+func (f levelLogger) Debugf(format string, args ...interface{}) {
+	if f.level >= LevelDebug {
+		f.l.Debugf(format, args...)
+	}
+}
+
+func (f levelLogger) Infof(format string, args ...interface{}) {
+	if f.level >= LevelInfo {
+		f.l.Infof(format, args...)
+	}
+}
+
+func (f levelLogger) Warnf(format string, args ...interface{}) {
+	if f.level >= LevelWarn {
+		f.l.Warnf(format, args...)
+	}
+}
+
+func (f levelLogger) Errorf(format string, args ...interface{}) {
+	if f.level >= LevelError {
+		f.l.Errorf(format, args...)
+	}
+}
+
+// tracerLogger logs to the Logger and level pinned by WithLogger and
+// WithLogLevel, falling back to those set by SetLogger and SetLogLevel at the
+// time of each call, so tracers keep following later changes to them.
+type tracerLogger struct {
+	l     Logger
+	level *LogLevel
+}
+
+// resolve returns the levelLogger to log the current message to.
+func (t tracerLogger) resolve() levelLogger {
+	l := levelLogger{baseLogger(), currentLogLevel()}
+	if t.l != nil {
+		l.l = t.l
+	}
+	if t.level != nil {
+		l.level = *t.level
+	}
+
+	return l
+}
+
+func (t tracerLogger) Debugf(format string, args ...interface{}) { t.resolve().Debugf(format, args...) }
+func (t tracerLogger) Infof(format string, args ...interface{})  { t.resolve().Infof(format, args...) }
+func (t tracerLogger) Warnf(format string, args ...interface{})  { t.resolve().Warnf(format, args...) }
+func (t tracerLogger) Errorf(format string, args ...interface{}) { t.resolve().Errorf(format, args...) }
+
+// jaegerLogger adapts a Logger to the interface expected by the Jaeger client.
+type jaegerLogger struct {
+	l Logger
+}
+
+func (j jaegerLogger) Error(msg string)                       { j.l.Errorf("%s", msg) }
+func (j jaegerLogger) Infof(msg string, args ...interface{})  { j.l.Infof(msg, args...) }
+func (j jaegerLogger) Debugf(msg string, args ...interface{}) { j.l.Debugf(msg, args...) }
@@ -0,0 +1,74 @@
+package hckit
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingLogger records the messages logged to it.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) { l.record("DEBUG", format, args) }
+func (l *recordingLogger) Infof(format string, args ...interface{})  { l.record("INFO", format, args) }
+func (l *recordingLogger) Warnf(format string, args ...interface{})  { l.record("WARN", format, args) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) { l.record("ERROR", format, args) }
+
+func (l *recordingLogger) record(level, format string, args []interface{}) {
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(format, args...))
+}
+
+// withPackageLogger sets the package Logger and LogLevel for the rest of the
+// test.
+func withPackageLogger(t *testing.T, l Logger, level LogLevel) {
+	oldLogger, oldLevel := baseLogger(), currentLogLevel()
+	SetLogger(l)
+	SetLogLevel(level)
+	t.Cleanup(func() {
+		SetLogger(oldLogger)
+		SetLogLevel(oldLevel)
+	})
+}
+
+func TestTracerLoggerFollowsPackageLogger(t *testing.T) {
+	first := &recordingLogger{}
+	withPackageLogger(t, first, LevelInfo)
+
+	l := jaegerLogger{newTracerConfig().tracerLogger()}
+	l.Infof("before %d", 1)
+
+	second := &recordingLogger{}
+	SetLogger(second)
+	SetLogLevel(LevelDebug)
+	l.Debugf("after %d", 2)
+	l.Error("failed")
+
+	if want := []string{"INFO: before 1"}; !reflect.DeepEqual(first.messages, want) {
+		t.Errorf("first logger messages = %q, want %q", first.messages, want)
+	}
+	if want := []string{"DEBUG: after 2", "ERROR: failed"}; !reflect.DeepEqual(second.messages, want) {
+		t.Errorf("second logger messages = %q, want %q", second.messages, want)
+	}
+}
+
+func TestTracerLoggerPinnedByOptions(t *testing.T) {
+	pkg := &recordingLogger{}
+	withPackageLogger(t, pkg, LevelDebug)
+
+	pinned := &recordingLogger{}
+	tc := newTracerConfig(WithLogger(pinned), WithLogLevel(LevelWarn))
+	l := jaegerLogger{tc.tracerLogger()}
+
+	SetLogger(&recordingLogger{})
+	l.Infof("dropped")
+	l.Error("failed")
+
+	if want := []string{"ERROR: failed"}; !reflect.DeepEqual(pinned.messages, want) {
+		t.Errorf("pinned logger messages = %q, want %q", pinned.messages, want)
+	}
+	if len(pkg.messages) != 0 {
+		t.Errorf("package logger messages = %q, want none", pkg.messages)
+	}
+}
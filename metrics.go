@@ -0,0 +1,57 @@
+package hckit
+
+import (
+	"net/http"
+	"time"
+)
+
+// UnmatchedOperation is the operation passed to Metrics for requests that did
+// not match a route, so that unknown paths do not each get their own series.
+const UnmatchedOperation = "unmatched"
+
+// Metrics records the rate, errors and duration of requests handled by the
+// tracing middleware. hckitprom provides an implementation backed by
+// Prometheus.
+type Metrics interface {
+	// ObserveRequest records a request to operation that completed with
+	// status after duration. errored reports whether its span was flagged as
+	// an error.
+	ObserveRequest(operation string, status int, errored bool, duration time.Duration)
+}
+
+// WithMetrics also records RED metrics for traced requests with m, labeled by
+// the same operation name as their spans; see WithOperationName. The default
+// operation name is the request path, which is unbounded, so requests named
+// after their path are labeled by their route template instead, see WithRoute,
+// or UnmatchedOperation when there is none.
+func WithMetrics(m Metrics) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.metrics = m
+	}
+}
+
+// observe records the metrics of r, which completed with status after starting
+// at start, counting it as an error if errored.
+func (c *middlewareConfig) observe(r *http.Request, status int, errored bool, start time.Time) {
+	if c.metrics == nil {
+		return
+	}
+
+	c.metrics.ObserveRequest(c.metricsOperation(r), status, errored, c.now().Sub(start))
+}
+
+// metricsOperation returns the low-cardinality operation r is recorded under.
+func (c *middlewareConfig) metricsOperation(r *http.Request) string {
+	operation := c.operationName(r)
+	if operation == r.URL.Path {
+		operation = c.route(r)
+		if operation == "" {
+			operation = UnmatchedOperation
+		}
+	}
+	if c.methodInName {
+		return r.Method + " " + operation
+	}
+
+	return operation
+}
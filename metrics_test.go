@@ -0,0 +1,105 @@
+package hckit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// recordedRequest is a request observed by fakeMetrics.
+type recordedRequest struct {
+	operation string
+	status    int
+	errored   bool
+}
+
+// fakeMetrics records the requests it observes.
+type fakeMetrics struct {
+	requests []recordedRequest
+}
+
+func (m *fakeMetrics) ObserveRequest(operation string, status int, errored bool, duration time.Duration) {
+	m.requests = append(m.requests, recordedRequest{operation, status, errored})
+}
+
+// testRoute returns the route template matching r in TestMetricsOperation.
+func testRoute(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/users/"):
+		return "/users/{id}"
+	case r.URL.Path == "/fail":
+		return "/fail"
+	default:
+		return ""
+	}
+}
+
+func TestMetricsOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []MiddlewareOption
+		path string
+		want recordedRequest
+	}{
+		{
+			name: "operation name",
+			opts: []MiddlewareOption{WithOperationName(func(*http.Request) string { return "GET /users/:id" })},
+			path: "/users/1",
+			want: recordedRequest{"GET /users/:id", http.StatusOK, false},
+		},
+		{
+			name: "operation name without a route",
+			opts: []MiddlewareOption{WithOperationName(func(*http.Request) string { return "legacy" })},
+			path: "/missing",
+			want: recordedRequest{"legacy", http.StatusNotFound, false},
+		},
+		{
+			name: "route when named after the path",
+			path: "/users/1",
+			want: recordedRequest{"/users/{id}", http.StatusOK, false},
+		},
+		{
+			name: "unmatched when named after the path without a route",
+			path: "/missing",
+			want: recordedRequest{UnmatchedOperation, http.StatusNotFound, false},
+		},
+		{
+			name: "method prefix",
+			opts: []MiddlewareOption{WithMethodInOperationName(true)},
+			path: "/users/1",
+			want: recordedRequest{"GET /users/{id}", http.StatusOK, false},
+		},
+		{
+			name: "errors",
+			path: "/fail",
+			want: recordedRequest{"/fail", http.StatusInternalServerError, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &fakeMetrics{}
+			opts := append([]MiddlewareOption{WithTracer(mocktracer.New()), WithMetrics(metrics), WithRoute(testRoute)}, tt.opts...)
+			handler := NewTracingMiddleware(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch testRoute(r) {
+				case "":
+					w.WriteHeader(http.StatusNotFound)
+				case "/fail":
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if len(metrics.requests) != 1 {
+				t.Fatalf("observed %d requests, want 1", len(metrics.requests))
+			}
+			if got := metrics.requests[0]; got != tt.want {
+				t.Errorf("observed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
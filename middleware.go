@@ -0,0 +1,709 @@
+package hckit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// Span tags set by the middleware in addition to the opentracing standard tags.
+const (
+	tagRequestSize  = "message.request.size"
+	tagResponseSize = "message.response.size"
+	tagHeaderPrefix = "http.header."
+	tagUserAgent    = "http.user_agent"
+	tagDebugID      = "jaeger-debug-id"
+	tagTTFB         = "http.ttfb_ms"
+	tagContentType  = "http.response.content_type"
+	tagHTTPRoute    = "http.route"
+	tagHTTPTarget   = "http.target"
+)
+
+// DefaultDebugHeader is the request header that forces a trace to be sampled
+// unless changed with WithDebugHeader. Its value is recorded on the span as
+// the jaeger-debug-id tag, so the trace can be found by searching for it.
+const DefaultDebugHeader = "jaeger-debug-id"
+
+// MiddlewareOption configures the middleware returned by NewTracingMiddleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds the settings used by the tracing middleware.
+type middlewareConfig struct {
+	// ignore holds predicates for requests that should not be traced.
+	ignore []func(*http.Request) bool
+
+	// traceOptions traces OPTIONS requests, which are skipped by default.
+	traceOptions bool
+
+	// isError reports whether a response marks the span as errored.
+	isError func(status int, err error) bool
+
+	// operationName returns the name of the server span for a request.
+	operationName func(*http.Request) string
+
+	// methodInName prefixes operation names with the request method.
+	methodInName bool
+
+	// route returns the route template that matched a request, or "" if it
+	// is not known.
+	route func(*http.Request) string
+
+	// query controls whether the query string is included in the http.url tag.
+	query queryPolicy
+
+	// capturedHeaders are the request headers recorded as span tags.
+	capturedHeaders []string
+
+	// sensitiveHeaders holds canonical header names whose values are recorded
+	// as redacted.
+	sensitiveHeaders map[string]struct{}
+
+	// trustedProxies is the number of proxies in front of the service whose
+	// X-Forwarded-For entries are trusted. Zero ignores the header.
+	trustedProxies int
+
+	// debugHeader names the request header that forces sampling, if any.
+	debugHeader string
+
+	// traceIDHeader names the response header carrying the trace ID, if any.
+	traceIDHeader string
+
+	// recoverPanics writes a 500 instead of re-panicking when a handler panics.
+	recoverPanics bool
+
+	// tracer is nil unless set by an option, in which case it replaces the
+	// global tracer.
+	tracer opentracing.Tracer
+
+	// maxTagLength limits the length of string tag values. Zero or less
+	// disables the limit.
+	maxTagLength int
+
+	// sampling overrides the tracer's sampling decision for new traces by
+	// path prefix.
+	sampling prefixRates
+
+	// now returns the current time for span timestamps and timings.
+	now func() time.Time
+
+	// metrics records RED metrics for traced requests, if set.
+	metrics Metrics
+}
+
+// newMiddlewareConfig returns the default settings with opts applied.
+func newMiddlewareConfig(opts ...MiddlewareOption) *middlewareConfig {
+	c := &middlewareConfig{
+		isError:          statusThreshold(http.StatusInternalServerError),
+		operationName:    pathOperationName,
+		route:            defaultRoute,
+		debugHeader:      DefaultDebugHeader,
+		sensitiveHeaders: headerSet(DefaultSensitiveHeaders),
+		maxTagLength:     defaultMaxTagLength,
+		now:              time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.ignore) == 0 {
+		c.ignore = append(c.ignore, isHealthCheck)
+	}
+
+	return c
+}
+
+// getTracer returns the configured tracer, or the global tracer at the time of
+// the request.
+func (c *middlewareConfig) getTracer() opentracing.Tracer {
+	if c.tracer != nil {
+		return c.tracer
+	}
+
+	return opentracing.GlobalTracer()
+}
+
+// ignored reports whether r should bypass tracing.
+func (c *middlewareConfig) ignored(r *http.Request) bool {
+	if r.Method == http.MethodOptions && !c.traceOptions {
+		return true
+	}
+
+	for _, fn := range c.ignore {
+		if fn(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHealthCheck is the default ignore predicate, matching any path that
+// contains "health".
+func isHealthCheck(r *http.Request) bool {
+	return strings.Contains(r.URL.Path, "health")
+}
+
+// pathOperationName is the default operation name, the request path.
+func pathOperationName(r *http.Request) string {
+	return r.URL.Path
+}
+
+// WithIgnoreFunc skips tracing for requests for which fn returns true. Supplying
+// any ignore option replaces the default of skipping paths containing "health".
+func WithIgnoreFunc(fn func(*http.Request) bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.ignore = append(c.ignore, fn)
+	}
+}
+
+// WithIgnoredPaths skips tracing for requests whose path exactly matches one of paths.
+func WithIgnoredPaths(paths []string) MiddlewareOption {
+	set := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		set[p] = struct{}{}
+	}
+
+	return WithIgnoreFunc(func(r *http.Request) bool {
+		_, ok := set[r.URL.Path]
+		return ok
+	})
+}
+
+// WithIgnoreRegexp skips tracing for requests whose path matches re. A nil re
+// has no effect, so the default of skipping health checks is kept.
+func WithIgnoreRegexp(re *regexp.Regexp) MiddlewareOption {
+	if re == nil {
+		return func(*middlewareConfig) {}
+	}
+
+	return WithIgnoreFunc(func(r *http.Request) bool {
+		return re.MatchString(r.URL.Path)
+	})
+}
+
+// WithTracer traces requests with tracer instead of opentracing.GlobalTracer,
+// e.g. to run several services with their own tracers in one process.
+func WithTracer(tracer opentracing.Tracer) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.tracer = tracer
+	}
+}
+
+// WithDebugHeader sets the request header that forces a request to be traced
+// regardless of the sampling rate, in place of DefaultDebugHeader. An empty
+// name disables forced sampling.
+func WithDebugHeader(name string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.debugHeader = name
+	}
+}
+
+// WithSamplingByPrefix samples new traces whose request path starts with a
+// prefix in rates at the given probability, e.g. 1 for "/api/checkout" and 0.01
+// for "/api/search", in place of the tracer's sampler. The longest matching
+// prefix wins; other paths keep the tracer's decision. Requests continuing an
+// upstream trace always keep its decision.
+func WithSamplingByPrefix(rates map[string]float64) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.sampling = newPrefixRates(rates)
+	}
+}
+
+// WithClock sets the time source used for the start and finish times of server
+// spans and the timings tagged on them, in place of time.Now, so tests can
+// make deterministic assertions about durations.
+func WithClock(now func() time.Time) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.now = now
+	}
+}
+
+// finish finishes span at the current time of the configured clock.
+func (c *middlewareConfig) finish(span opentracing.Span) {
+	span.FinishWithOptions(opentracing.FinishOptions{FinishTime: c.now()})
+}
+
+// WithTraceOptions controls whether OPTIONS requests, such as CORS preflights,
+// are traced. They are skipped by default, independently of the ignore options.
+func WithTraceOptions(enabled bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.traceOptions = enabled
+	}
+}
+
+// WithErrorStatusThreshold marks spans as errored when the response status is at
+// least code. The default is 500, so 4xx responses are not flagged; pass 400 to
+// treat client errors as failures too.
+func WithErrorStatusThreshold(code int) MiddlewareOption {
+	return WithErrorPredicate(statusThreshold(code))
+}
+
+// WithErrorPredicate sets the function deciding whether a response marks the
+// span as errored, e.g. to accept 409 from an endpoint that returns it in
+// normal flows or to flag 404 as a failure. err is nil unless the handler
+// panicked. It replaces WithErrorStatusThreshold.
+func WithErrorPredicate(fn func(status int, err error) bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.isError = fn
+	}
+}
+
+// statusThreshold returns an error predicate flagging panics and statuses of at
+// least code.
+func statusThreshold(code int) func(int, error) bool {
+	return func(status int, err error) bool {
+		return err != nil || status >= code
+	}
+}
+
+// WithRecoverPanics controls what happens after a panicking handler has been
+// recorded on the span, with a 500 status unless the handler had already sent
+// a response. By default the panic is re-raised; when enabled it is recovered
+// and the 500 is written. http.ErrAbortHandler is always re-raised, and is not
+// recorded as an error.
+func WithRecoverPanics(enabled bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.recoverPanics = enabled
+	}
+}
+
+// WithOperationName sets the function used to name server spans. Returning a
+// route template such as "GET /users/:id" rather than the raw path keeps the
+// number of distinct operations low.
+func WithOperationName(fn func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.operationName = fn
+	}
+}
+
+// WithRoute sets the function returning the route template that matched a
+// request, e.g. "/users/{id}", recorded as the http.route tag once the handler
+// returns. By default the pattern matched by an http.ServeMux, from Go 1.23, or
+// a gorilla/mux router is used; hckitgin and hckitchi use their router's
+// route.
+func WithRoute(fn func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.route = fn
+	}
+}
+
+// defaultRoute returns the path of the http.ServeMux pattern that matched r,
+// or else the gorilla/mux route template.
+func defaultRoute(r *http.Request) string {
+	// Patterns have the form "[METHOD ][HOST]/[PATH]".
+	pattern := serveMuxPattern(r)
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return pattern[i:]
+	}
+
+	return muxRoute(r)
+}
+
+// WithMethodInOperationName controls whether server span names are prefixed
+// with the request method, e.g. "DELETE /users/{id}", so that methods sharing a
+// route are separate operations. The default is false, keeping existing
+// operation names, and the prefixes given to WithOperationSampling, unchanged.
+func WithMethodInOperationName(enabled bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.methodInName = enabled
+	}
+}
+
+// spanName returns the operation name of the server span for r.
+func (c *middlewareConfig) spanName(r *http.Request) string {
+	if c.methodInName {
+		return r.Method + " " + c.operationName(r)
+	}
+
+	return c.operationName(r)
+}
+
+// WithQueryParams includes the query string in the http.url tag, which is
+// otherwise omitted. Values of the sensitive keys, matched case-insensitively,
+// are redacted.
+func WithQueryParams(sensitive ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.query.includeQuery(sensitive)
+	}
+}
+
+// WithAllowedQueryParams includes only the listed query parameters, matched
+// case-insensitively, in the http.url tag, e.g. "page" but not "token". Every
+// other parameter is dropped. Values of keys passed to WithQueryParams are
+// still redacted.
+func WithAllowedQueryParams(allowed ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.query.allowQuery(allowed)
+	}
+}
+
+// WithCapturedHeaders records the first value of each named request header as
+// a span tag named http.header.<lower-cased name>, e.g. http.header.x-request-id.
+// Only the listed headers are captured, and the values of sensitive headers are
+// recorded as "[REDACTED]"; see WithSensitiveHeaders.
+func WithCapturedHeaders(headers []string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.capturedHeaders = append(c.capturedHeaders, headers...)
+	}
+}
+
+// DefaultSensitiveHeaders are the request headers whose values are never
+// recorded on spans unless replaced with WithSensitiveHeaders.
+var DefaultSensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// WithSensitiveHeaders replaces DefaultSensitiveHeaders as the headers whose
+// values are recorded as "[REDACTED]" when captured. Passing no headers keeps
+// the defaults, so redaction cannot be disabled by accident.
+func WithSensitiveHeaders(headers ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		if len(headers) > 0 {
+			c.sensitiveHeaders = headerSet(headers)
+		}
+	}
+}
+
+// headerSet returns the canonical forms of headers as a set.
+func headerSet(headers []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(headers))
+	for _, h := range headers {
+		set[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	return set
+}
+
+// headerTag returns the tag value recording header name's value.
+func (c *middlewareConfig) headerTag(name, value string) string {
+	if _, ok := c.sensitiveHeaders[http.CanonicalHeaderKey(name)]; ok {
+		return redacted
+	}
+
+	return value
+}
+
+// WithMaxTagLength truncates string tag values, such as http.url and
+// http.user_agent, to at most n bytes ending in "...", so that oversized values
+// are not rejected by the backend. The default is 1024; zero or less disables
+// truncation. Raise WithMaxTagValueLength on the tracer to match larger limits.
+func WithMaxTagLength(n int) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.maxTagLength = n
+	}
+}
+
+// setStringTag sets a string tag on span, truncated to the configured length.
+func (c *middlewareConfig) setStringTag(span opentracing.Span, key, value string) {
+	span.SetTag(key, truncateTag(value, c.maxTagLength))
+}
+
+// WithTrustedProxies derives the peer.address, peer.ipv4 and peer.ipv6 tags of
+// the server span from the X-Forwarded-For header instead of the connection's
+// remote address. n is the number of proxies in front of the service that
+// append to the header; entries added by anything else are client-controlled
+// and are ignored. The header is ignored unless this option is given, so only
+// enable it behind proxies you operate.
+func WithTrustedProxies(n int) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.trustedProxies = n
+	}
+}
+
+// WithTraceIDHeader writes the trace ID of each traced request into the
+// response header name, e.g. X-Trace-Id, so a failing request can be matched
+// to its trace from the client. The header is set before the handler runs.
+func WithTraceIDHeader(name string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.traceIDHeader = name
+	}
+}
+
+// TracingMiddleware returns an HTTP Handler appropriate for Middleware chaining via Router.Use.
+// Requests to any path containing "health" are not traced; use NewTracingMiddleware to
+// configure this.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return NewTracingMiddleware()(next)
+}
+
+// NewTracingMiddleware returns a TracingMiddleware configured with opts.
+func NewTracingMiddleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return newMiddlewareConfig(opts...).middleware
+}
+
+// middleware traces requests to next.
+func (c *middlewareConfig) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.ignored(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := c.now()
+		span, r := c.startSpan(w, r, start)
+		defer c.finish(span)
+
+		rec := &statusRecorder{ResponseWriter: w, now: c.now}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			status := http.StatusInternalServerError
+			if rec.status != 0 {
+				status = rec.status
+			}
+			c.tagTTFB(span, rec, start)
+
+			// http.ErrAbortHandler is used to deliberately abort a response
+			// and must always reach net/http. It is not an error.
+			if p == http.ErrAbortHandler {
+				c.tagResponse(span, r, status, rec.written, rec.Header())
+				c.observe(r, status, false, start)
+				panic(p)
+			}
+
+			err := fmt.Errorf("panic: %v", p)
+			errored := c.finishSpan(span, r, status, rec.written, rec.Header(), err)
+			c.observe(r, status, errored, start)
+			span.LogFields(
+				otlog.String("event", "error"),
+				otlog.String("error.kind", "panic"),
+				otlog.Object("error.object", p),
+				otlog.String("stack", string(debug.Stack())),
+			)
+
+			if !c.recoverPanics {
+				panic(p)
+			}
+			if rec.status == 0 {
+				rec.WriteHeader(status)
+			}
+		}()
+
+		next.ServeHTTP(rec.writer(), r)
+
+		c.tagTTFB(span, rec, start)
+		errored := c.finishSpan(span, r, rec.statusCode(), rec.written, rec.Header(), nil)
+		c.observe(r, rec.statusCode(), errored, start)
+	})
+}
+
+// tagTTFB tags span with the time from start to the first byte recorded by
+// rec, if any was written.
+func (c *middlewareConfig) tagTTFB(span opentracing.Span, rec *statusRecorder, start time.Time) {
+	if !rec.firstByte.IsZero() {
+		span.SetTag(tagTTFB, float64(rec.firstByte.Sub(start))/float64(time.Millisecond))
+	}
+}
+
+// WrapHandler traces a single handler as NewTracingMiddleware(opts...) does, for
+// giving one endpoint its own options, such as an operation name or sampling
+// rate, without a sub-router:
+//
+//	mux.Handle("/checkout", hckit.WrapHandler(checkout,
+//		hckit.WithOperationName(func(*http.Request) string { return "checkout" }),
+//	))
+func WrapHandler(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	return NewTracingMiddleware(opts...)(next)
+}
+
+// WrapHandlerFunc traces a single handler function as WrapHandler does.
+func WrapHandlerFunc(next http.HandlerFunc, opts ...MiddlewareOption) http.Handler {
+	return WrapHandler(next, opts...)
+}
+
+// Middleware traces requests as NewTracingMiddleware does, for adapters to
+// routers whose middleware cannot wrap an http.Handler, such as hckitgin.
+type Middleware struct {
+	c *middlewareConfig
+}
+
+// NewMiddleware returns a Middleware configured by opts.
+func NewMiddleware(opts ...MiddlewareOption) *Middleware {
+	return &Middleware{c: newMiddlewareConfig(opts...)}
+}
+
+// Handler traces requests to next as NewTracingMiddleware does.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return m.c.middleware(next)
+}
+
+// OperationName returns the name of the server span for r, including the
+// method prefix added by WithMethodInOperationName. Adapters for routers that
+// only match a route after the span has started, such as hckitchi, rename the
+// span with it once the handler returns.
+func (m *Middleware) OperationName(r *http.Request) string {
+	return m.c.spanName(r)
+}
+
+// Ignored reports whether r should not be traced because of WithIgnoreFunc,
+// WithIgnoredPaths or WithIgnoreRegexp.
+func (m *Middleware) Ignored(r *http.Request) bool {
+	return m.c.ignored(r)
+}
+
+// Start starts the server span for r and returns it with a shallow copy of r
+// whose context carries it. Pass the copy to the handler, and call Finish once
+// the handler returns.
+func (m *Middleware) Start(w http.ResponseWriter, r *http.Request) (*ServerSpan, *http.Request) {
+	start := m.c.now()
+	span, r := m.c.startSpan(w, r, start)
+
+	return &ServerSpan{c: m.c, span: span, r: r, start: start}, r
+}
+
+// ServerSpan is a server span started by Middleware.Start.
+type ServerSpan struct {
+	c     *middlewareConfig
+	span  opentracing.Span
+	r     *http.Request
+	start time.Time
+}
+
+// Span returns the underlying span.
+func (s *ServerSpan) Span() opentracing.Span {
+	return s.span
+}
+
+// Finish tags the span with the response status, the number of body bytes
+// written and the response header, records its metrics, and finishes it.
+func (s *ServerSpan) Finish(status int, written int64, header http.Header) {
+	errored := s.c.finishSpan(s.span, s.r, status, written, header, nil)
+	s.c.observe(s.r, status, errored, s.start)
+	s.c.finish(s.span)
+}
+
+// startSpan starts the server span for r at start, tagging it from the request,
+// and returns it with a shallow copy of r whose context carries it.
+func (c *middlewareConfig) startSpan(w http.ResponseWriter, r *http.Request, start time.Time) (opentracing.Span, *http.Request) {
+	logger().Debugf("TracingMiddleware beginning for %s---------------------------", r.URL.Path)
+
+	tracer := c.getTracer()
+	// If no context exists an error will be returned, but we ignore it because
+	// if ctx == nil, a root span will be created.
+	wireContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	if err == opentracing.ErrSpanContextNotFound {
+		logger().Debugf("No span context found for %s", r.URL.Path)
+	} else if err == ErrNotSampled {
+		logger().Debugf("Caller decided not to sample %s", r.URL.Path)
+	} else if err != nil {
+		logger().Warnf("Extract failed, error received.\n%v\n", err)
+	}
+	// Not every tracer returns a nil context with the error, and continuing an
+	// empty one would drop the baggage and trace from the span.
+	if err != nil {
+		wireContext = nil
+	}
+
+	if wireContext != nil {
+		logger().Debugf("WireContext is %v", wireContext)
+	}
+	// A span continuing an upstream trace inherits its sampling decision, as
+	// does a root span whose caller decided not to sample; the local sampler
+	// only applies to other root spans.
+	span := tracer.StartSpan(c.spanName(r), ext.RPCServerOption(wireContext), opentracing.StartTime(start))
+
+	if err == ErrNotSampled {
+		ext.SamplingPriority.Set(span, 0)
+	} else if wireContext == nil {
+		if sampled, ok := c.sampling.sample(r.URL.Path); ok {
+			if sampled {
+				ext.SamplingPriority.Set(span, 1)
+			} else {
+				ext.SamplingPriority.Set(span, 0)
+			}
+		}
+	}
+
+	if c.debugHeader != "" {
+		if id := r.Header.Get(c.debugHeader); id != "" {
+			ext.SamplingPriority.Set(span, 1)
+			c.setStringTag(span, tagDebugID, c.headerTag(c.debugHeader, id))
+		}
+	}
+
+	ext.HTTPMethod.Set(span, r.Method)
+	c.setStringTag(span, string(ext.HTTPUrl), c.query.urlTag(r.URL))
+	c.setStringTag(span, tagHTTPTarget, c.query.urlTag(&url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}))
+	peer := clientAddress(r, c.trustedProxies)
+	c.setStringTag(span, string(ext.PeerAddress), peer)
+	setPeerIPTag(span, peer)
+	if ua := r.UserAgent(); ua != "" {
+		c.setStringTag(span, tagUserAgent, ua)
+	}
+
+	for _, name := range c.capturedHeaders {
+		if value := r.Header.Get(name); value != "" {
+			c.setStringTag(span, tagHeaderPrefix+strings.ToLower(name), c.headerTag(name, value))
+		}
+	}
+
+	// ContentLength is -1 when the size is not known up front, e.g. for
+	// chunked requests.
+	if r.ContentLength >= 0 {
+		span.SetTag(tagRequestSize, r.ContentLength)
+	} else {
+		span.SetTag(tagRequestSize, "unknown")
+	}
+
+	span.LogFields(
+		otlog.String("event", r.URL.Path),
+		otlog.String("value", "start"),
+	)
+
+	// Make the span available to downstream handlers via opentracing.SpanFromContext.
+	r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+
+	if c.traceIDHeader != "" {
+		if traceID, ok := TraceIDFromContext(r.Context()); ok {
+			w.Header().Set(c.traceIDHeader, traceID)
+		}
+	}
+
+	return span, r
+}
+
+// finishSpan tags span with the response to r and flags it as errored
+// according to the configured predicate, given err from the handler. It
+// reports whether the span was flagged, and does not finish it.
+func (c *middlewareConfig) finishSpan(span opentracing.Span, r *http.Request, status int, written int64, header http.Header, err error) bool {
+	c.tagResponse(span, r, status, written, header)
+
+	errored := c.isError(status, err)
+	if errored {
+		ext.Error.Set(span, true)
+	}
+
+	return errored
+}
+
+// tagResponse tags span with the response to r.
+func (c *middlewareConfig) tagResponse(span opentracing.Span, r *http.Request, status int, written int64, header http.Header) {
+	ext.HTTPStatusCode.Set(span, uint16(status))
+	span.SetTag(tagResponseSize, written)
+	// A Content-Type sniffed by net/http is not in the header map.
+	if ct := header.Get("Content-Type"); ct != "" {
+		c.setStringTag(span, tagContentType, ct)
+	}
+	if route := c.route(r); route != "" {
+		c.setStringTag(span, tagHTTPRoute, route)
+	}
+	setDeadlineTag(span, r.Context())
+
+	span.LogFields(
+		otlog.String("event", r.URL.Path),
+		otlog.String("value", "finish"),
+	)
+
+	logger().Debugf("TracingMiddleware complete----------------------------------------------")
+}
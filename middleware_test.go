@@ -0,0 +1,124 @@
+package hckit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestMiddlewareRecordsPanics(t *testing.T) {
+	tests := []struct {
+		name      string
+		recover   bool
+		panicWith interface{}
+		repanics  bool
+		errored   bool
+	}{
+		{"re-raised", false, "boom", true, true},
+		{"recovered", true, "boom", false, true},
+		{"aborted", false, http.ErrAbortHandler, true, false},
+		{"aborted with recovery", true, http.ErrAbortHandler, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := mocktracer.New()
+			metrics := &fakeMetrics{}
+			handler := NewTracingMiddleware(
+				WithTracer(tracer),
+				WithMetrics(metrics),
+				WithRecoverPanics(tt.recover),
+				WithRoute(func(*http.Request) string { return "/panics" }),
+			)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				panic(tt.panicWith)
+			}))
+
+			rec := httptest.NewRecorder()
+			repanicked := func() (repanicked bool) {
+				defer func() { repanicked = recover() != nil }()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panics", nil))
+				return false
+			}()
+			if repanicked != tt.repanics {
+				t.Errorf("re-raised = %t, want %t", repanicked, tt.repanics)
+			}
+			if tt.recover && !tt.repanics && rec.Code != http.StatusInternalServerError {
+				t.Errorf("status written = %d, want 500", rec.Code)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 1 {
+				t.Fatalf("finished %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			for key, want := range map[string]interface{}{
+				"http.status_code": uint16(http.StatusInternalServerError),
+				tagHTTPRoute:       "/panics",
+				tagContentType:     "text/plain",
+				tagResponseSize:    int64(0),
+			} {
+				if got := span.Tag(key); got != want {
+					t.Errorf("tag %s = %v (%T), want %v (%T)", key, got, got, want, want)
+				}
+			}
+			if got := span.Tag("error") == true; got != tt.errored {
+				t.Errorf("error tag = %t, want %t", got, tt.errored)
+			}
+			if got := hasStackLog(span); got != tt.errored {
+				t.Errorf("stack logged = %t, want %t", got, tt.errored)
+			}
+
+			if len(metrics.requests) != 1 {
+				t.Fatalf("observed %d requests, want 1", len(metrics.requests))
+			}
+			if got := metrics.requests[0]; got.status != http.StatusInternalServerError || got.errored != tt.errored {
+				t.Errorf("observed %+v, want status 500 and errored %t", got, tt.errored)
+			}
+		})
+	}
+}
+
+// hasStackLog reports whether span has a log record carrying a stack trace.
+func hasStackLog(span *mocktracer.MockSpan) bool {
+	for _, record := range span.Logs() {
+		for _, field := range record.Fields {
+			if field.Key == "stack" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func TestMiddlewareIgnored(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []MiddlewareOption
+		path string
+		want bool
+	}{
+		{"default health check", nil, "/health", true},
+		{"default traced", nil, "/users", false},
+		{"regexp", []MiddlewareOption{WithIgnoreRegexp(regexp.MustCompile("^/internal/"))}, "/internal/metrics", true},
+		{"regexp replaces health check", []MiddlewareOption{WithIgnoreRegexp(regexp.MustCompile("^/internal/"))}, "/health", false},
+		{"nil regexp keeps health check", []MiddlewareOption{WithIgnoreRegexp(nil)}, "/health", true},
+		{"nil regexp", []MiddlewareOption{WithIgnoreRegexp(nil)}, "/users", false},
+		{"paths", []MiddlewareOption{WithIgnoredPaths([]string{"/ready"})}, "/ready", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiddleware(tt.opts...)
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			if got := m.Ignored(r); got != tt.want {
+				t.Errorf("Ignored(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
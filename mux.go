@@ -0,0 +1,31 @@
+package hckit
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// MuxOperationName names spans after the matched gorilla/mux route template,
+// e.g. /products/{id}, falling back to the request path. Use it with
+// WithOperationName; the route is only known when the middleware is
+// registered via Router.Use.
+func MuxOperationName(r *http.Request) string {
+	if route := muxRoute(r); route != "" {
+		return route
+	}
+
+	return r.URL.Path
+}
+
+// muxRoute returns the template of the gorilla/mux route that matched r, or ""
+// if there is none.
+func muxRoute(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+
+	return ""
+}
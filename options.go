@@ -0,0 +1,359 @@
+package hckit
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	jaeger "github.com/uber/jaeger-client-go"
+	config "github.com/uber/jaeger-client-go/config"
+	"github.com/uber/jaeger-lib/metrics"
+)
+
+// Option configures the tracer created by InitGlobalTracer.
+type Option func(*tracerConfig)
+
+// tracerConfig holds the settings InitGlobalTracer applies on top of the
+// configuration loaded from the environment.
+type tracerConfig struct {
+	// sampler is nil unless set by an option, in which case it replaces any
+	// sampler loaded from the environment.
+	sampler        *config.SamplerConfig
+	metricsFactory metrics.Factory
+
+	// logSpans is nil unless set by an option, in which case it replaces
+	// JAEGER_REPORTER_LOG_SPANS.
+	logSpans *bool
+
+	// logger is nil unless set by an option, in which case it replaces the
+	// Jaeger client's standard logger.
+	logger Logger
+
+	// logLevel is nil unless set by an option, in which case it replaces the
+	// level set by SetLogLevel.
+	logLevel *LogLevel
+
+	// operationRates maps operation name prefixes to sampling probabilities.
+	operationRates map[string]float64
+
+	// disabled is nil unless set by an option, in which case it replaces
+	// JAEGER_DISABLED.
+	disabled *bool
+
+	// agentHostPort replaces JAEGER_AGENT_HOST and JAEGER_AGENT_PORT when set.
+	agentHostPort string
+
+	// collectorEndpoint, collectorUser and collectorPassword replace
+	// JAEGER_ENDPOINT, JAEGER_USER and JAEGER_PASSWORD when set.
+	collectorEndpoint string
+	collectorUser     string
+	collectorPassword string
+
+	// gen128Bit generates 128-bit trace IDs for new traces.
+	gen128Bit bool
+
+	// xrayTraceIDs generates 128-bit trace IDs that begin with the trace's
+	// start time in seconds, as AWS X-Ray requires.
+	xrayTraceIDs bool
+
+	// sharedRPCSpan makes server spans share the span ID of the client span
+	// they continue, as Zipkin does.
+	sharedRPCSpan bool
+
+	// maxTagValueLength limits the length of string tag values reported by
+	// the Jaeger client.
+	maxTagValueLength int
+
+	// queueSize and flushInterval replace JAEGER_REPORTER_MAX_QUEUE_SIZE and
+	// JAEGER_REPORTER_FLUSH_INTERVAL when set.
+	queueSize     int
+	flushInterval time.Duration
+
+	// spanFile is the path spans are also written to, if any.
+	spanFile string
+
+	// tags are added to the process tags loaded from JAEGER_TAGS.
+	tags map[string]string
+
+	// versionEnv and environmentEnv name the environment variables read for
+	// the service.version and deployment.environment process tags. Empty
+	// names disable the tags.
+	versionEnv     string
+	environmentEnv string
+
+	// extractors are tried in order to extract span contexts from HTTP headers.
+	extractors []jaeger.Extractor
+	// injectors all inject span contexts into HTTP headers.
+	injectors []jaeger.Injector
+}
+
+// newTracerConfig returns the default settings with opts applied. The defaults
+// discard client metrics, propagate Zipkin B3 headers and share RPC spans.
+func newTracerConfig(opts ...Option) *tracerConfig {
+	b3 := newB3Propagator()
+	c := &tracerConfig{
+		metricsFactory:    metrics.NullFactory,
+		maxTagValueLength: defaultMaxTagLength,
+		sharedRPCSpan:     true,
+		versionEnv:        "SERVICE_VERSION",
+		environmentEnv:    "DEPLOY_ENV",
+		extractors:        []jaeger.Extractor{b3},
+		injectors:         []jaeger.Injector{b3},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithSampler sets the sampler type and parameter, e.g.
+// WithSampler(jaeger.SamplerTypeProbabilistic, 0.01). It takes precedence over
+// JAEGER_SAMPLER_TYPE and JAEGER_SAMPLER_PARAM. The sampler only decides for
+// traces that start in this service; spans continuing an extracted trace keep
+// the caller's decision.
+func WithSampler(samplerType string, param float64) Option {
+	return func(c *tracerConfig) {
+		c.sampler = &config.SamplerConfig{
+			Type:  samplerType,
+			Param: param,
+		}
+	}
+}
+
+// WithRateLimitingSampler samples at most maxTracesPerSecond new traces per
+// second, bounding tracing overhead regardless of traffic.
+func WithRateLimitingSampler(maxTracesPerSecond float64) Option {
+	return WithSampler(jaeger.SamplerTypeRateLimiting, maxTracesPerSecond)
+}
+
+// WithRemoteSampler uses sampling strategies served by the Jaeger agent at
+// samplingServerURL, e.g. "http://jaeger-agent:5778/sampling", polling for
+// changes every refreshInterval. An empty URL or zero interval keeps the Jaeger
+// client's default.
+func WithRemoteSampler(samplingServerURL string, refreshInterval time.Duration) Option {
+	return func(c *tracerConfig) {
+		c.sampler = &config.SamplerConfig{
+			Type:                    jaeger.SamplerTypeRemote,
+			SamplingServerURL:       samplingServerURL,
+			SamplingRefreshInterval: refreshInterval,
+		}
+	}
+}
+
+// WithOperationSampling samples traces whose root operation name starts with a
+// prefix in rates at the given probability, e.g. 1 for "/api/checkout" and
+// 0.01 for "/static/". The longest matching prefix wins; other operations use
+// the sampler chosen as described by InitGlobalTracer.
+func WithOperationSampling(rates map[string]float64) Option {
+	return func(c *tracerConfig) {
+		c.operationRates = rates
+	}
+}
+
+// WithLogSpans controls whether every reported span is also logged, taking
+// precedence over JAEGER_REPORTER_LOG_SPANS. When neither is set spans are
+// logged.
+func WithLogSpans(enabled bool) Option {
+	return func(c *tracerConfig) {
+		c.logSpans = &enabled
+	}
+}
+
+// WithMetricsFactory sets the factory used for the Jaeger client's internal
+// metrics, such as spans reported and dropped and the reporter queue length.
+// The default, metrics.NullFactory, discards them. To export them to Prometheus
+// use github.com/uber/jaeger-lib/metrics/prometheus:
+//
+//	InitGlobalTracer("service", WithMetricsFactory(
+//		prometheus.New(prometheus.WithRegisterer(registerer)),
+//	))
+func WithMetricsFactory(f metrics.Factory) Option {
+	return func(c *tracerConfig) {
+		c.metricsFactory = f
+	}
+}
+
+// WithLogger routes the Jaeger client's log output, including logged spans,
+// through l. InitGlobalTracer also installs l via SetLogger. Without it, the
+// tracer logs to whichever Logger SetLogger last set.
+func WithLogger(l Logger) Option {
+	return func(c *tracerConfig) {
+		c.logger = l
+	}
+}
+
+// WithLogLevel sets the most verbose level logged by the tracer. InitGlobalTracer
+// also applies it via SetLogLevel. Without it, the tracer follows the level
+// SetLogLevel last set.
+func WithLogLevel(level LogLevel) Option {
+	return func(c *tracerConfig) {
+		c.logLevel = &level
+	}
+}
+
+// WithPropagator sets the Propagator used for HTTP headers and other TextMap
+// carriers, such as message headers, in place of the default Zipkin B3
+// propagator, e.g. WithPropagator(NewW3CPropagator()) or
+// WithPropagator(NewDatadogPropagator()).
+func WithPropagator(p Propagator) Option {
+	return WithPropagators(p)
+}
+
+// WithPropagators registers several Propagators for HTTP headers, e.g. to accept
+// both B3 and W3C Trace Context during a migration. Extraction tries each in
+// order and uses the first span context found; injection writes every format
+// unless WithInjectors is also given.
+func WithPropagators(ps ...Propagator) Option {
+	return func(c *tracerConfig) {
+		c.extractors = make([]jaeger.Extractor, len(ps))
+		c.injectors = make([]jaeger.Injector, len(ps))
+		for i, p := range ps {
+			c.extractors[i] = p
+			c.injectors[i] = p
+		}
+	}
+}
+
+// WithInjectors sets the formats written into outbound HTTP headers,
+// independently of the formats accepted on extraction.
+func WithInjectors(injectors ...jaeger.Injector) Option {
+	return func(c *tracerConfig) {
+		c.injectors = injectors
+	}
+}
+
+// WithDisabled controls whether tracing is disabled, taking precedence over
+// JAEGER_DISABLED. A disabled tracer is an opentracing.NoopTracer with a no-op
+// closer; no reporter is started and the middleware and HTTP client helpers
+// keep working without recording anything.
+func WithDisabled(disabled bool) Option {
+	return func(c *tracerConfig) {
+		c.disabled = &disabled
+	}
+}
+
+// WithAgentHostPort sends spans to the jaeger-agent at host and port, taking
+// precedence over JAEGER_AGENT_HOST and JAEGER_AGENT_PORT.
+func WithAgentHostPort(host string, port int) Option {
+	return func(c *tracerConfig) {
+		c.agentHostPort = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+}
+
+// WithCollectorEndpoint sends spans directly to the jaeger-collector at endpoint
+// over HTTP, e.g. http://jaeger-collector:14268/api/traces, taking precedence
+// over JAEGER_ENDPOINT. When set, the collector is used instead of the agent.
+func WithCollectorEndpoint(endpoint string) Option {
+	return func(c *tracerConfig) {
+		c.collectorEndpoint = endpoint
+	}
+}
+
+// WithCollectorBasicAuth sets the credentials used to authenticate with the
+// collector endpoint, taking precedence over JAEGER_USER and JAEGER_PASSWORD.
+func WithCollectorBasicAuth(user, password string) Option {
+	return func(c *tracerConfig) {
+		c.collectorUser = user
+		c.collectorPassword = password
+	}
+}
+
+// WithReporterQueueSize sets how many spans the reporter buffers before
+// dropping them, taking precedence over JAEGER_REPORTER_MAX_QUEUE_SIZE. Raise it
+// if spans are dropped during traffic spikes.
+func WithReporterQueueSize(size int) Option {
+	return func(c *tracerConfig) {
+		c.queueSize = size
+	}
+}
+
+// WithReporterFlushInterval sets how often the reporter flushes buffered spans,
+// taking precedence over JAEGER_REPORTER_FLUSH_INTERVAL.
+func WithReporterFlushInterval(interval time.Duration) Option {
+	return func(c *tracerConfig) {
+		c.flushInterval = interval
+	}
+}
+
+// WithSpanFile also writes every reported span to the file at path as a line of
+// JSON, with its IDs, operation name, timing and tags, for inspecting traces
+// locally without a Jaeger backend. The file is appended to if it exists.
+// Spans are still sent to the agent or collector; when none is running the
+// agent's UDP packets are dropped harmlessly.
+func WithSpanFile(path string) Option {
+	return func(c *tracerConfig) {
+		c.spanFile = path
+	}
+}
+
+// WithTags adds process-level tags, such as service.version or region, to every
+// span reported by the tracer. The tags are added to any set via JAEGER_TAGS,
+// SERVICE_VERSION or DEPLOY_ENV, replacing those with the same key.
+func WithTags(tags map[string]string) Option {
+	return func(c *tracerConfig) {
+		if c.tags == nil {
+			c.tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			c.tags[k] = v
+		}
+	}
+}
+
+// WithVersionEnv sets the environment variable whose value, when set, is added
+// to every span as the service.version process tag. The default is
+// SERVICE_VERSION; an empty name disables the tag.
+func WithVersionEnv(name string) Option {
+	return func(c *tracerConfig) {
+		c.versionEnv = name
+	}
+}
+
+// WithEnvironmentEnv sets the environment variable whose value, when set, is
+// added to every span as the deployment.environment process tag. The default
+// is DEPLOY_ENV; an empty name disables the tag.
+func WithEnvironmentEnv(name string) Option {
+	return func(c *tracerConfig) {
+		c.environmentEnv = name
+	}
+}
+
+// WithGen128Bit controls whether new traces get 128-bit trace IDs, as used by
+// OpenTelemetry and W3C Trace Context. The default is 64-bit IDs.
+func WithGen128Bit(enabled bool) Option {
+	return func(c *tracerConfig) {
+		c.gen128Bit = enabled
+	}
+}
+
+// WithXRayTraceIDs generates 128-bit trace IDs that AWS X-Ray accepts, whose
+// first 32 bits are the trace's start time in seconds, for use with
+// NewXRayPropagator. It overrides WithGen128Bit. Baggage restrictions and
+// throttling set in the configuration given to InitGlobalTracerWithConfig are
+// not supported with it.
+func WithXRayTraceIDs() Option {
+	return func(c *tracerConfig) {
+		c.xrayTraceIDs = true
+	}
+}
+
+// WithSharedRPCSpan controls whether a server span continuing a trace shares the
+// span ID of the calling client span, as Zipkin does, so that the two appear
+// as a single span. The default is true; disable it when interoperating with
+// tools that expect distinct client and server spans.
+func WithSharedRPCSpan(enabled bool) Option {
+	return func(c *tracerConfig) {
+		c.sharedRPCSpan = enabled
+	}
+}
+
+// WithMaxTagValueLength sets the length in bytes beyond which the Jaeger client
+// truncates string tag values. The default is 1024, matching the limit applied
+// by the middleware and client spans.
+func WithMaxTagValueLength(n int) Option {
+	return func(c *tracerConfig) {
+		c.maxTagValueLength = n
+	}
+}
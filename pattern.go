@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package hckit
+
+import "net/http"
+
+// serveMuxPattern returns the http.ServeMux pattern that matched r.
+func serveMuxPattern(r *http.Request) string {
+	return r.Pattern
+}
@@ -0,0 +1,11 @@
+//go:build !go1.23
+
+package hckit
+
+import "net/http"
+
+// serveMuxPattern returns "", as http.Request only records the matched
+// http.ServeMux pattern from Go 1.23.
+func serveMuxPattern(r *http.Request) string {
+	return ""
+}
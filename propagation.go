@@ -0,0 +1,606 @@
+package hckit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/zipkin"
+)
+
+// ErrNotSampled is returned when extracting from a carrier that holds a
+// caller's decision not to sample the trace but no span context, such as the
+// "b3: 0" header sent by Envoy or a lone X-B3-Sampled: 0. The middleware
+// honors it by starting a root span that is not sampled.
+var ErrNotSampled = errors.New("span context not found, caller decided not to sample")
+
+// Propagator injects Jaeger span contexts into, and extracts them from,
+// HTTP header carriers.
+type Propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+// multiInjector injects a span context using every injector.
+type multiInjector []jaeger.Injector
+
+// Inject implements jaeger.Injector.
+func (m multiInjector) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	for _, injector := range m {
+		if err := injector.Inject(sc, carrier); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chainedExtractor tries each extractor in order, returning the first span
+// context found.
+type chainedExtractor []jaeger.Extractor
+
+// Extract implements jaeger.Extractor. When no extractor finds a span context
+// it returns the first error other than opentracing.ErrSpanContextNotFound.
+func (c chainedExtractor) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	result := opentracing.ErrSpanContextNotFound
+	for _, extractor := range c {
+		sc, err := extractor.Extract(carrier)
+		if err == nil {
+			return sc, nil
+		}
+		if result == opentracing.ErrSpanContextNotFound {
+			result = err
+		}
+	}
+
+	return jaeger.SpanContext{}, result
+}
+
+// Zipkin B3 header names.
+const (
+	// b3SampledHeader carries the Zipkin B3 sampling decision.
+	b3SampledHeader = "x-b3-sampled"
+	// b3FlagsHeader carries the Zipkin B3 debug flag.
+	b3FlagsHeader = "x-b3-flags"
+	// b3SingleHeader carries a whole span context in the B3 single-header
+	// format.
+	b3SingleHeader = "b3"
+	// b3BaggagePrefix prefixes baggage headers, as for zipkin.Propagator.
+	b3BaggagePrefix = "baggage-"
+)
+
+// b3Propagator propagates Zipkin B3 headers. Unlike zipkin.Propagator it
+// treats X-B3-Flags: 1 (debug) as a sampled trace, so a caller forcing a trace
+// is not dropped here, and on extraction it also accepts the single b3 header
+// sent by newer Envoy and Istio deployments. Injection writes the X-B3-*
+// headers, which every B3 implementation understands.
+type b3Propagator struct {
+	zipkin.Propagator
+}
+
+// newB3Propagator returns the default B3 Propagator.
+func newB3Propagator() Propagator {
+	return b3Propagator{zipkin.NewZipkinB3HTTPHeaderPropagator()}
+}
+
+// Extract implements jaeger.Extractor. The X-B3-* headers take precedence over
+// the b3 header when both are present. ErrNotSampled is returned when the
+// headers only carry a decision not to sample.
+func (p b3Propagator) Extract(abstractCarrier interface{}) (jaeger.SpanContext, error) {
+	sc, err := p.Propagator.Extract(abstractCarrier)
+	if err == opentracing.ErrSpanContextNotFound {
+		carrier := abstractCarrier.(opentracing.TextMapReader)
+		sc, err = extractB3Single(carrier)
+		if err == opentracing.ErrSpanContextNotFound && b3Header(carrier, b3SampledHeader) == "0" {
+			err = ErrNotSampled
+		}
+		return sc, err
+	}
+	if err != nil || sc.IsSampled() {
+		return sc, err
+	}
+
+	carrier := abstractCarrier.(opentracing.TextMapReader)
+	debug := b3Header(carrier, b3FlagsHeader) == "1"
+	if !debug {
+		return sc, nil
+	}
+
+	baggage := make(map[string]string)
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		baggage[k] = v
+		return true
+	})
+
+	return jaeger.NewSpanContext(sc.TraceID(), sc.SpanID(), sc.ParentID(), true, baggage), nil
+}
+
+// b3Header returns the value of the header named key, in lower case, in
+// carrier.
+func b3Header(carrier opentracing.TextMapReader, key string) string {
+	var header string
+	carrier.ForeachKey(func(k, v string) error {
+		if strings.ToLower(k) == key {
+			header = v
+		}
+		return nil
+	})
+
+	return header
+}
+
+// extractB3Single extracts a span context from the b3 header in carrier, along
+// with any baggage headers.
+func extractB3Single(carrier opentracing.TextMapReader) (jaeger.SpanContext, error) {
+	var header string
+	var baggage map[string]string
+	carrier.ForeachKey(func(rawKey, value string) error {
+		key := strings.ToLower(rawKey)
+		if key == b3SingleHeader {
+			header = value
+		} else if strings.HasPrefix(key, b3BaggagePrefix) {
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[key[len(b3BaggagePrefix):]] = value
+		}
+		return nil
+	})
+	if header == "" {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	return parseB3Single(header, baggage)
+}
+
+// parseB3Single parses a b3 header value of the form
+// traceid-spanid[-sampled[-parentspanid]], where sampled is 0, 1 or d (debug).
+// A value holding only a sampling decision carries no span context; for "0"
+// ErrNotSampled is returned so the decision is not lost.
+func parseB3Single(value string, baggage map[string]string) (jaeger.SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) == 1 {
+		if parts[0] == "0" {
+			return jaeger.SpanContext{}, ErrNotSampled
+		}
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	if len(parts) > 4 || (len(parts[0]) != 16 && len(parts[0]) != 32) || len(parts[1]) != 16 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	if !isLowerHex(parts[0]) || !isLowerHex(parts[1]) || (len(parts) > 3 && !isLowerHex(parts[3])) {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+
+	traceID, err := jaeger.TraceIDFromString(parts[0])
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	spanID, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+
+	sampled := false
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1", "d":
+			sampled = true
+		case "0":
+		default:
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+		}
+	}
+
+	var parentID uint64
+	if len(parts) > 3 {
+		if len(parts[3]) != 16 {
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+		}
+		if parentID, err = strconv.ParseUint(parts[3], 16, 64); err != nil {
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+		}
+	}
+
+	if !traceID.IsValid() || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), jaeger.SpanID(parentID), sampled, baggage), nil
+}
+
+// W3C Trace Context and Baggage header names.
+const (
+	traceparentHeader = "traceparent"
+	baggageHeader     = "baggage"
+)
+
+// w3cPropagator propagates span contexts using the W3C Trace Context
+// traceparent header, and baggage using the W3C baggage header.
+type w3cPropagator struct{}
+
+// NewW3CPropagator returns a Propagator for W3C Trace Context, for exchanging
+// traces with services instrumented by OpenTelemetry. The tracestate header
+// is not propagated.
+func NewW3CPropagator() Propagator {
+	return w3cPropagator{}
+}
+
+// Inject implements jaeger.Injector.
+func (w3cPropagator) Inject(sc jaeger.SpanContext, abstractCarrier interface{}) error {
+	carrier, ok := abstractCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	traceID := sc.TraceID()
+	carrier.Set(traceparentHeader, fmt.Sprintf("00-%016x%016x-%016x-%s", traceID.High, traceID.Low, uint64(sc.SpanID()), flags))
+
+	var members []string
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		members = append(members, k+"="+url.PathEscape(v))
+		return true
+	})
+	if len(members) > 0 {
+		carrier.Set(baggageHeader, strings.Join(members, ","))
+	}
+
+	return nil
+}
+
+// Extract implements jaeger.Extractor.
+func (w3cPropagator) Extract(abstractCarrier interface{}) (jaeger.SpanContext, error) {
+	carrier, ok := abstractCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	var traceparent string
+	var baggage map[string]string
+	err := carrier.ForeachKey(func(key, value string) error {
+		switch strings.ToLower(key) {
+		case traceparentHeader:
+			traceparent = value
+		case baggageHeader:
+			baggage = parseW3CBaggage(value, baggage)
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if traceparent == "" {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	traceID, spanID, sampled, err := parseTraceparent(traceparent)
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+
+	return jaeger.NewSpanContext(traceID, spanID, 0, sampled, baggage), nil
+}
+
+// parseTraceparent parses a traceparent header value of the form
+// version-traceid-parentid-flags.
+func parseTraceparent(value string) (jaeger.TraceID, jaeger.SpanID, bool, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// Future versions may append fields, but version 00 has exactly four.
+	if len(parts) < 4 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+	if len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+	for _, part := range parts[:4] {
+		if !isLowerHex(part) {
+			return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+		}
+	}
+
+	high, err := strconv.ParseUint(parts[1][:16], 16, 64)
+	if err != nil {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+	low, err := strconv.ParseUint(parts[1][16:], 16, 64)
+	if err != nil {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+	spanID, err := strconv.ParseUint(parts[2], 16, 64)
+	if err != nil {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+
+	traceID := jaeger.TraceID{High: high, Low: low}
+	if !traceID.IsValid() || spanID == 0 {
+		return jaeger.TraceID{}, 0, false, opentracing.ErrSpanContextCorrupted
+	}
+
+	return traceID, jaeger.SpanID(spanID), flags&1 == 1, nil
+}
+
+// parseW3CBaggage adds the members of a baggage header value to baggage,
+// ignoring any member properties.
+func parseW3CBaggage(value string, baggage map[string]string) map[string]string {
+	for _, member := range strings.Split(value, ",") {
+		if i := strings.Index(member, ";"); i >= 0 {
+			member = member[:i]
+		}
+		kv := strings.SplitN(member, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(kv[0])
+		val, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if key == "" || err != nil {
+			continue
+		}
+
+		if baggage == nil {
+			baggage = make(map[string]string)
+		}
+		baggage[key] = val
+	}
+
+	return baggage
+}
+
+// Datadog header names.
+const (
+	datadogTraceIDHeader  = "x-datadog-trace-id"
+	datadogParentIDHeader = "x-datadog-parent-id"
+	datadogPriorityHeader = "x-datadog-sampling-priority"
+	datadogTagsHeader     = "x-datadog-tags"
+	datadogBaggagePrefix  = "ot-baggage-"
+
+	// datadogTraceIDHighTag carries the upper 64 bits of 128-bit trace IDs in
+	// the x-datadog-tags header.
+	datadogTraceIDHighTag = "_dd.p.tid"
+)
+
+// datadogPropagator propagates span contexts using the headers of the Datadog
+// tracer.
+type datadogPropagator struct{}
+
+// NewDatadogPropagator returns a Propagator for the x-datadog-* headers used by
+// services instrumented with the Datadog tracer.
+func NewDatadogPropagator() Propagator {
+	return datadogPropagator{}
+}
+
+// Inject implements jaeger.Injector.
+func (datadogPropagator) Inject(sc jaeger.SpanContext, abstractCarrier interface{}) error {
+	carrier, ok := abstractCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	traceID := sc.TraceID()
+	carrier.Set(datadogTraceIDHeader, strconv.FormatUint(traceID.Low, 10))
+	carrier.Set(datadogParentIDHeader, strconv.FormatUint(uint64(sc.SpanID()), 10))
+	if traceID.High != 0 {
+		carrier.Set(datadogTagsHeader, fmt.Sprintf("%s=%016x", datadogTraceIDHighTag, traceID.High))
+	}
+	if sc.IsSampled() {
+		carrier.Set(datadogPriorityHeader, "1")
+	} else {
+		carrier.Set(datadogPriorityHeader, "0")
+	}
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		carrier.Set(datadogBaggagePrefix+k, v)
+		return true
+	})
+
+	return nil
+}
+
+// Extract implements jaeger.Extractor.
+func (datadogPropagator) Extract(abstractCarrier interface{}) (jaeger.SpanContext, error) {
+	carrier, ok := abstractCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	var traceID jaeger.TraceID
+	var spanID uint64
+	sampled := false
+	var baggage map[string]string
+	err := carrier.ForeachKey(func(rawKey, value string) error {
+		var err error
+		switch key := strings.ToLower(rawKey); {
+		case key == datadogTraceIDHeader:
+			traceID.Low, err = strconv.ParseUint(value, 10, 64)
+		case key == datadogParentIDHeader:
+			spanID, err = strconv.ParseUint(value, 10, 64)
+		case key == datadogPriorityHeader:
+			// Priorities above zero are sampled, 2 being a user decision.
+			priority, perr := strconv.Atoi(value)
+			sampled = perr == nil && priority > 0
+		case key == datadogTagsHeader:
+			traceID.High = parseDatadogTraceIDHigh(value)
+		case strings.HasPrefix(key, datadogBaggagePrefix):
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[key[len(datadogBaggagePrefix):]] = value
+		}
+		if err != nil {
+			return opentracing.ErrSpanContextCorrupted
+		}
+		return nil
+	})
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	if traceID.Low == 0 || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), 0, sampled, baggage), nil
+}
+
+// parseDatadogTraceIDHigh returns the upper 64 trace ID bits from an
+// x-datadog-tags value, or zero when they are absent.
+func parseDatadogTraceIDHigh(value string) uint64 {
+	for _, tag := range strings.Split(value, ",") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 2 && kv[0] == datadogTraceIDHighTag {
+			high, err := strconv.ParseUint(kv[1], 16, 64)
+			if err == nil {
+				return high
+			}
+		}
+	}
+
+	return 0
+}
+
+// AWS X-Ray header name and fields.
+const (
+	xrayHeader        = "x-amzn-trace-id"
+	xrayRootField     = "Root"
+	xrayParentField   = "Parent"
+	xraySampledField  = "Sampled"
+	xrayTraceIDPrefix = "1-"
+)
+
+// xrayPropagator propagates span contexts using the X-Amzn-Trace-Id header of
+// AWS X-Ray.
+type xrayPropagator struct{}
+
+// NewXRayPropagator returns a Propagator for the X-Amzn-Trace-Id header used by
+// AWS X-Ray and services such as API Gateway and ALB. X-Ray trace IDs begin with
+// the trace's start time in seconds, so combine it with WithXRayTraceIDs for
+// trace IDs that X-Ray accepts. Baggage is not propagated.
+func NewXRayPropagator() Propagator {
+	return xrayPropagator{}
+}
+
+// Inject implements jaeger.Injector.
+func (xrayPropagator) Inject(sc jaeger.SpanContext, abstractCarrier interface{}) error {
+	carrier, ok := abstractCarrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	// The 128-bit trace ID is split into X-Ray's 8 digit epoch and 24 digit
+	// unique identifier.
+	traceID := fmt.Sprintf("%016x%016x", sc.TraceID().High, sc.TraceID().Low)
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	carrier.Set(xrayHeader, fmt.Sprintf("%s=%s%s-%s;%s=%016x;%s=%s",
+		xrayRootField, xrayTraceIDPrefix, traceID[:8], traceID[8:],
+		xrayParentField, uint64(sc.SpanID()),
+		xraySampledField, sampled,
+	))
+
+	return nil
+}
+
+// Extract implements jaeger.Extractor.
+func (xrayPropagator) Extract(abstractCarrier interface{}) (jaeger.SpanContext, error) {
+	carrier, ok := abstractCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrInvalidCarrier
+	}
+
+	var header string
+	carrier.ForeachKey(func(key, value string) error {
+		if strings.ToLower(key) == xrayHeader {
+			header = value
+		}
+		return nil
+	})
+	if header == "" {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	return parseXRayHeader(header)
+}
+
+// parseXRayHeader parses an X-Amzn-Trace-Id value such as
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1".
+func parseXRayHeader(header string) (jaeger.SpanContext, error) {
+	var traceID jaeger.TraceID
+	var spanID uint64
+	sampled := false
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		var err error
+		switch kv[0] {
+		case xrayRootField:
+			parts := strings.Split(kv[1], "-")
+			if len(parts) != 3 || parts[0]+"-" != xrayTraceIDPrefix || len(parts[1]) != 8 || len(parts[2]) != 24 ||
+				!isLowerHex(parts[1]) || !isLowerHex(parts[2]) {
+				return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+			}
+			traceID, err = jaeger.TraceIDFromString(parts[1] + parts[2])
+		case xrayParentField:
+			if len(kv[1]) != 16 || !isLowerHex(kv[1]) {
+				return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+			}
+			spanID, err = strconv.ParseUint(kv[1], 16, 64)
+		case xraySampledField:
+			sampled = kv[1] == "1"
+		}
+		if err != nil {
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	if !traceID.IsValid() || spanID == 0 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+
+	return jaeger.NewSpanContext(traceID, jaeger.SpanID(spanID), 0, sampled, nil), nil
+}
+
+// isLowerHex reports whether s consists only of lowercase hexadecimal digits,
+// the only encoding the W3C, B3 and X-Ray header formats allow.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// InjectTextMap injects the context of the span in ctx into carrier, for
+// propagating traces through anything that carries string key/values, such as
+// job payloads or message headers. It does nothing when ctx has no span.
+func InjectTextMap(ctx context.Context, carrier opentracing.TextMapWriter) error {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+
+	return span.Tracer().Inject(span.Context(), opentracing.TextMap, carrier)
+}
+
+// ExtractTextMap extracts a span context injected by InjectTextMap from carrier
+// using the GlobalTracer. It returns opentracing.ErrSpanContextNotFound when
+// carrier holds none.
+func ExtractTextMap(carrier opentracing.TextMapReader) (opentracing.SpanContext, error) {
+	return opentracing.GlobalTracer().Extract(opentracing.TextMap, carrier)
+}
@@ -0,0 +1,192 @@
+package hckit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		traceID string
+		spanID  jaeger.SpanID
+		sampled bool
+		err     error
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", 0x00f067aa0ba902b7, true, nil},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "4bf92f3577b34da6a3ce929d0e0e4736", 0x00f067aa0ba902b7, false, nil},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-cafe", "4bf92f3577b34da6a3ce929d0e0e4736", 0x00f067aa0ba902b7, true, nil},
+		{"version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-cafe", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"short trace ID", "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"short span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"missing flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase trace ID", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"all-zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"all-zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", 0, false, opentracing.ErrSpanContextCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traceID, spanID, sampled, err := parseTraceparent(tt.value)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if traceID.String() != tt.traceID || spanID != tt.spanID || sampled != tt.sampled {
+				t.Errorf("got %s, %s, %t, want %s, %s, %t", traceID, spanID, sampled, tt.traceID, tt.spanID, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestParseB3Single(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		traceID  string
+		spanID   jaeger.SpanID
+		parentID jaeger.SpanID
+		sampled  bool
+		err      error
+	}{
+		{"valid", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90", "80f198ee56343ba864fe8b2a57d3eff7", 0xe457b5a2e4d86bd1, 0x05e3ac9a4f6e3b90, true, nil},
+		{"64-bit trace ID", "a3ce929d0e0e4736-e457b5a2e4d86bd1-0", "a3ce929d0e0e4736", 0xe457b5a2e4d86bd1, 0, false, nil},
+		{"debug", "a3ce929d0e0e4736-e457b5a2e4d86bd1-d", "a3ce929d0e0e4736", 0xe457b5a2e4d86bd1, 0, true, nil},
+		{"sampling deferred", "a3ce929d0e0e4736-e457b5a2e4d86bd1", "a3ce929d0e0e4736", 0xe457b5a2e4d86bd1, 0, false, nil},
+		{"deny only", "0", "", 0, 0, false, ErrNotSampled},
+		{"accept only", "1", "", 0, 0, false, opentracing.ErrSpanContextNotFound},
+		{"short trace ID", "a3ce929d0e0e473-e457b5a2e4d86bd1-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"short span ID", "a3ce929d0e0e4736-e457b5a2e4d86bd-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"short parent ID", "a3ce929d0e0e4736-e457b5a2e4d86bd1-1-05e3ac9a", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase trace ID", "A3CE929D0E0E4736-e457b5a2e4d86bd1-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase span ID", "a3ce929d0e0e4736-E457B5A2E4D86BD1-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"invalid sampling state", "a3ce929d0e0e4736-e457b5a2e4d86bd1-x", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"all-zero trace ID", "0000000000000000-e457b5a2e4d86bd1-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"all-zero span ID", "a3ce929d0e0e4736-0000000000000000-1", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+		{"extra field", "a3ce929d0e0e4736-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90-00", "", 0, 0, false, opentracing.ErrSpanContextCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := parseB3Single(tt.value, nil)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if sc.TraceID().String() != tt.traceID || sc.SpanID() != tt.spanID || sc.ParentID() != tt.parentID || sc.IsSampled() != tt.sampled {
+				t.Errorf("got %s, want %s:%s:%s sampled=%t", sc, tt.traceID, tt.spanID, tt.parentID, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestMiddlewareHonorsDenyOnlyB3(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{"single header", "b3", "0"},
+		{"multiple headers", "X-B3-Sampled", "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, closer, err := NewTracer("b3-test", WithLogSpans(false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer closer.Close()
+
+			sampled := true
+			handler := NewTracingMiddleware(WithTracer(tracer))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				span := opentracing.SpanFromContext(r.Context())
+				sampled = span.Context().(jaeger.SpanContext).IsSampled()
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(tt.header, tt.value)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if sampled {
+				t.Errorf("span is sampled after %s: %s", tt.header, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseXRayHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		traceID string
+		spanID  jaeger.SpanID
+		sampled bool
+		err     error
+	}{
+		{"valid", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1", "5759e988bd862e3fe1be46a994272793", 0x53995c3f42cd8ad8, true, nil},
+		{"not sampled", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0", "5759e988bd862e3fe1be46a994272793", 0x53995c3f42cd8ad8, false, nil},
+		{"extra field", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1;Lineage=a87bd80c:0", "5759e988bd862e3fe1be46a994272793", 0x53995c3f42cd8ad8, true, nil},
+		{"no parent", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1", "", 0, false, opentracing.ErrSpanContextNotFound},
+		{"short root", "Root=1-5759e988-bd862e3fe1be46a99427279;Parent=53995c3f42cd8ad8;Sampled=1", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"short parent", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad;Sampled=1", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"wrong version", "Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase root", "Root=1-5759E988-BD862E3FE1BE46A994272793;Parent=53995c3f42cd8ad8;Sampled=1", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"uppercase parent", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995C3F42CD8AD8;Sampled=1", "", 0, false, opentracing.ErrSpanContextCorrupted},
+		{"all-zero root", "Root=1-00000000-000000000000000000000000;Parent=53995c3f42cd8ad8;Sampled=1", "", 0, false, opentracing.ErrSpanContextNotFound},
+		{"all-zero parent", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=0000000000000000;Sampled=1", "", 0, false, opentracing.ErrSpanContextNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := parseXRayHeader(tt.value)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if sc.TraceID().String() != tt.traceID || sc.SpanID() != tt.spanID || sc.IsSampled() != tt.sampled {
+				t.Errorf("got %s, want %s:%s sampled=%t", sc, tt.traceID, tt.spanID, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestXRayTraceIDsStartWithEpoch(t *testing.T) {
+	tracer, closer, err := NewTracer("xray-test", WithXRayTraceIDs(), WithPropagator(NewXRayPropagator()), WithLogSpans(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+
+	before := time.Now().Unix()
+	span := tracer.StartSpan("root")
+	defer span.Finish()
+	after := time.Now().Unix()
+
+	carrier := opentracing.TextMapCarrier{}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := parseXRayHeader(carrier[xrayHeader])
+	if err != nil {
+		t.Fatalf("parseXRayHeader(%q) = %v", carrier[xrayHeader], err)
+	}
+
+	if epoch := int64(sc.TraceID().High >> 32); epoch < before || epoch > after {
+		t.Errorf("trace ID epoch = %d, want between %d and %d", epoch, before, after)
+	}
+}
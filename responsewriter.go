@@ -0,0 +1,146 @@
+package hckit
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code,
+// number of body bytes written by a handler and when the response started.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	// firstByte is when the status was sent, or zero if it has not been.
+	firstByte time.Time
+	// now returns the current time.
+	now func() time.Time
+}
+
+// WriteHeader records code before delegating to the wrapped ResponseWriter.
+func (w *statusRecorder) WriteHeader(code int) {
+	w.record(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 if WriteHeader has not been called.
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.record(http.StatusOK)
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter for http.ResponseController.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// record records code and the current time as the start of the response, the
+// first time it is called with a final status. Informational 1xx codes other
+// than 101 Switching Protocols precede the final status and are ignored.
+func (w *statusRecorder) record(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+		w.firstByte = w.now()
+	}
+}
+
+// statusCode returns the status sent to the client, which is 200 when the
+// handler wrote nothing.
+func (w *statusRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// flusherFunc adapts a function to http.Flusher.
+type flusherFunc func()
+
+// Flush implements http.Flusher.
+func (f flusherFunc) Flush() {
+	f()
+}
+
+// hijackerFunc adapts a function to http.Hijacker.
+type hijackerFunc func() (net.Conn, *bufio.ReadWriter, error)
+
+// Hijack implements http.Hijacker.
+func (f hijackerFunc) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return f()
+}
+
+// writer returns w as an http.ResponseWriter that also implements whichever of
+// http.Flusher, http.Hijacker and http.Pusher the wrapped ResponseWriter does,
+// so streaming, websocket upgrades and HTTP/2 push keep working when traced.
+func (w *statusRecorder) writer() http.ResponseWriter {
+	f, isFlusher := w.ResponseWriter.(http.Flusher)
+	hj, isHijacker := w.ResponseWriter.(http.Hijacker)
+	p, isPusher := w.ResponseWriter.(http.Pusher)
+
+	// Flushing sends the headers, so it records an implicit 200 like Write.
+	flush := flusherFunc(func() {
+		w.record(http.StatusOK)
+		f.Flush()
+	})
+	// Whatever is sent on a hijacked connection is invisible here; it is
+	// almost always a protocol upgrade, so record 101 rather than 200.
+	h := hijackerFunc(func() (net.Conn, *bufio.ReadWriter, error) {
+		conn, rw, err := hj.Hijack()
+		if err == nil {
+			w.record(http.StatusSwitchingProtocols)
+		}
+		return conn, rw, err
+	})
+
+	switch {
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			*statusRecorder
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, flush, h, p}
+	case isFlusher && isHijacker:
+		return struct {
+			*statusRecorder
+			http.Flusher
+			http.Hijacker
+		}{w, flush, h}
+	case isFlusher && isPusher:
+		return struct {
+			*statusRecorder
+			http.Flusher
+			http.Pusher
+		}{w, flush, p}
+	case isHijacker && isPusher:
+		return struct {
+			*statusRecorder
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case isFlusher:
+		return struct {
+			*statusRecorder
+			http.Flusher
+		}{w, flush}
+	case isHijacker:
+		return struct {
+			*statusRecorder
+			http.Hijacker
+		}{w, h}
+	case isPusher:
+		return struct {
+			*statusRecorder
+			http.Pusher
+		}{w, p}
+	default:
+		return w
+	}
+}
@@ -0,0 +1,215 @@
+package hckit
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestStatusRecorderIgnoresInformational(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes []int
+		want  int
+	}{
+		{"none", nil, http.StatusOK},
+		{"final", []int{http.StatusNotFound}, http.StatusNotFound},
+		{"first final wins", []int{http.StatusCreated, http.StatusInternalServerError}, http.StatusCreated},
+		{"early hints", []int{http.StatusEarlyHints, http.StatusInternalServerError}, http.StatusInternalServerError},
+		{"continue", []int{http.StatusContinue, http.StatusOK}, http.StatusOK},
+		{"switching protocols", []int{http.StatusSwitchingProtocols}, http.StatusSwitchingProtocols},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &statusRecorder{ResponseWriter: httptest.NewRecorder(), now: time.Now}
+			for _, code := range tt.codes {
+				rec.WriteHeader(code)
+			}
+
+			if got := rec.statusCode(); got != tt.want {
+				t.Errorf("statusCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareTagsFinalStatusAfterEarlyHints(t *testing.T) {
+	tracer := mocktracer.New()
+	handler := NewTracingMiddleware(WithTracer(tracer))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if got := spans[0].Tag("http.status_code"); got != uint16(http.StatusInternalServerError) {
+		t.Errorf("http.status_code = %v, want 500", got)
+	}
+	if got := spans[0].Tag("error"); got != true {
+		t.Errorf("error = %v, want true", got)
+	}
+}
+
+// fakeWriter is an http.ResponseWriter whose optional interfaces are selected
+// by embedding it in the types below.
+type fakeWriter struct {
+	// ResponseWriter is an interface so that the recorder's Flush method is
+	// not promoted.
+	http.ResponseWriter
+	flushed  bool
+	hijacked bool
+	pushed   bool
+}
+
+func (w *fakeWriter) flush() { w.flushed = true }
+
+func (w *fakeWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *fakeWriter) push(string, *http.PushOptions) error {
+	w.pushed = true
+	return nil
+}
+
+type plainWriter struct{ *fakeWriter }
+
+type flushWriter struct{ *fakeWriter }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *fakeWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type pushWriter struct{ *fakeWriter }
+
+func (w pushWriter) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+type flushHijackWriter struct{ *fakeWriter }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushPushWriter struct{ *fakeWriter }
+
+func (w flushPushWriter) Flush() { w.flush() }
+
+func (w flushPushWriter) Push(target string, opts *http.PushOptions) error {
+	return w.push(target, opts)
+}
+
+type hijackPushWriter struct{ *fakeWriter }
+
+func (w hijackPushWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+func (w hijackPushWriter) Push(target string, opts *http.PushOptions) error {
+	return w.push(target, opts)
+}
+
+type allWriter struct{ *fakeWriter }
+
+func (w allWriter) Flush() { w.flush() }
+
+func (w allWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+func (w allWriter) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+func TestStatusRecorderWriterInterfaces(t *testing.T) {
+	tests := []struct {
+		name                    string
+		wrap                    func(*fakeWriter) http.ResponseWriter
+		flusher, hijacker, push bool
+	}{
+		{"plain", func(f *fakeWriter) http.ResponseWriter { return plainWriter{f} }, false, false, false},
+		{"flusher", func(f *fakeWriter) http.ResponseWriter { return flushWriter{f} }, true, false, false},
+		{"hijacker", func(f *fakeWriter) http.ResponseWriter { return hijackWriter{f} }, false, true, false},
+		{"pusher", func(f *fakeWriter) http.ResponseWriter { return pushWriter{f} }, false, false, true},
+		{"flusher hijacker", func(f *fakeWriter) http.ResponseWriter { return flushHijackWriter{f} }, true, true, false},
+		{"flusher pusher", func(f *fakeWriter) http.ResponseWriter { return flushPushWriter{f} }, true, false, true},
+		{"hijacker pusher", func(f *fakeWriter) http.ResponseWriter { return hijackPushWriter{f} }, false, true, true},
+		{"all", func(f *fakeWriter) http.ResponseWriter { return allWriter{f} }, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeWriter{ResponseWriter: httptest.NewRecorder()}
+			w := (&statusRecorder{ResponseWriter: tt.wrap(fake), now: time.Now}).writer()
+
+			f, ok := w.(http.Flusher)
+			if ok != tt.flusher {
+				t.Fatalf("implements http.Flusher = %t, want %t", ok, tt.flusher)
+			}
+			if ok {
+				f.Flush()
+				if !fake.flushed {
+					t.Error("Flush was not passed to the wrapped writer")
+				}
+			}
+
+			h, ok := w.(http.Hijacker)
+			if ok != tt.hijacker {
+				t.Fatalf("implements http.Hijacker = %t, want %t", ok, tt.hijacker)
+			}
+			if ok {
+				h.Hijack()
+				if !fake.hijacked {
+					t.Error("Hijack was not passed to the wrapped writer")
+				}
+			}
+
+			p, ok := w.(http.Pusher)
+			if ok != tt.push {
+				t.Fatalf("implements http.Pusher = %t, want %t", ok, tt.push)
+			}
+			if ok {
+				p.Push("/style.css", nil)
+				if !fake.pushed {
+					t.Error("Push was not passed to the wrapped writer")
+				}
+			}
+		})
+	}
+}
+
+func TestStatusRecorderRecordsImplicitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(http.ResponseWriter)
+		want int
+	}{
+		{"flush", func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, http.StatusOK},
+		{"hijack", func(w http.ResponseWriter) { w.(http.Hijacker).Hijack() }, http.StatusSwitchingProtocols},
+		{"header then hijack", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.(http.Hijacker).Hijack()
+		}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeWriter{ResponseWriter: httptest.NewRecorder()}
+			rec := &statusRecorder{ResponseWriter: allWriter{fake}, now: time.Now}
+			tt.use(rec.writer())
+
+			if got := rec.statusCode(); got != tt.want {
+				t.Errorf("statusCode() = %d, want %d", got, tt.want)
+			}
+			if rec.firstByte.IsZero() {
+				t.Error("firstByte was not recorded")
+			}
+		})
+	}
+}
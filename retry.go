@@ -0,0 +1,42 @@
+package hckit
+
+import (
+	"context"
+	"sync/atomic"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// tagRetryAttempt holds the 1-based attempt number of a retried client call.
+const tagRetryAttempt = "retry.attempt"
+
+// retryKey is the context key holding the attempt counter of a retried call.
+type retryKey struct{}
+
+// StartRetrySpan starts a span for a logical call that may be retried, as a
+// child of the span in ctx. Each request sent by a TracingRoundTripper with the
+// returned context becomes a child span of it tagged with retry.attempt, so a
+// success preceded by failed attempts is visible in the trace. Pass the context
+// to a retrying client that wraps a client from WrapClient, e.g.
+//
+//	span, ctx := hckit.StartRetrySpan(ctx, "GET products")
+//	defer span.Finish()
+//	resp, err := retryingClient.Do(req.WithContext(ctx))
+//
+// It returns the span and a context carrying it. The caller must finish the span.
+func StartRetrySpan(ctx context.Context, operationName string) (opentracing.Span, context.Context) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, operationName)
+
+	return span, context.WithValue(ctx, retryKey{}, new(atomic.Int64))
+}
+
+// nextRetryAttempt returns the number of the attempt starting with ctx, or
+// false if ctx was not returned by StartRetrySpan.
+func nextRetryAttempt(ctx context.Context) (int64, bool) {
+	attempts, ok := ctx.Value(retryKey{}).(*atomic.Int64)
+	if !ok {
+		return 0, false
+	}
+
+	return attempts.Add(1), true
+}
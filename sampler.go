@@ -0,0 +1,98 @@
+package hckit
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// operationSampler samples traces at a rate chosen by the longest operation
+// name prefix that matches the root span, deferring to a fallback sampler for
+// operations matching no prefix.
+type operationSampler struct {
+	// prefixes are ordered longest first.
+	prefixes []string
+	samplers map[string]jaeger.Sampler
+	fallback jaeger.Sampler
+}
+
+// newOperationSampler returns a sampler using the probability in rates for
+// operations starting with each prefix.
+func newOperationSampler(rates map[string]float64, fallback jaeger.Sampler) (*operationSampler, error) {
+	s := &operationSampler{
+		samplers: make(map[string]jaeger.Sampler, len(rates)),
+		fallback: fallback,
+	}
+
+	for prefix, rate := range rates {
+		sampler, err := jaeger.NewProbabilisticSampler(rate)
+		if err != nil {
+			return nil, err
+		}
+		s.prefixes = append(s.prefixes, prefix)
+		s.samplers[prefix] = sampler
+	}
+	sort.Slice(s.prefixes, func(i, j int) bool {
+		return len(s.prefixes[i]) > len(s.prefixes[j])
+	})
+
+	return s, nil
+}
+
+// IsSampled implements jaeger.Sampler.
+func (s *operationSampler) IsSampled(id jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return s.samplers[prefix].IsSampled(id, operation)
+		}
+	}
+
+	return s.fallback.IsSampled(id, operation)
+}
+
+// Close implements jaeger.Sampler.
+func (s *operationSampler) Close() {
+	for _, sampler := range s.samplers {
+		sampler.Close()
+	}
+	s.fallback.Close()
+}
+
+// Equal implements jaeger.Sampler.
+func (s *operationSampler) Equal(other jaeger.Sampler) bool {
+	return s == other
+}
+
+// prefixRates maps path prefixes to sampling probabilities for the middleware.
+type prefixRates struct {
+	// prefixes are ordered longest first.
+	prefixes []string
+	rates    map[string]float64
+}
+
+// newPrefixRates returns the probabilities in rates ordered for matching.
+func newPrefixRates(rates map[string]float64) prefixRates {
+	p := prefixRates{rates: rates}
+	for prefix := range rates {
+		p.prefixes = append(p.prefixes, prefix)
+	}
+	sort.Slice(p.prefixes, func(i, j int) bool {
+		return len(p.prefixes[i]) > len(p.prefixes[j])
+	})
+
+	return p
+}
+
+// sample reports whether a trace for path is sampled according to the longest
+// matching prefix, and false for ok when no prefix matches.
+func (p prefixRates) sample(path string) (sampled, ok bool) {
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return rand.Float64() < p.rates[prefix], true
+		}
+	}
+
+	return false, false
+}
@@ -0,0 +1,317 @@
+package hckit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// WrapConnector returns a driver.Connector whose connections trace every query
+// and statement execution with a client span, for use with sql.OpenDB:
+//
+//	db := sql.OpenDB(hckit.WrapConnector(connector))
+//
+// Spans are named "SQL <OPERATION>", e.g. "SQL SELECT", and tagged as by
+// StartDBSpan. Queries are only traced when their context carries a span, so
+// background work such as connection pool maintenance does not start new
+// traces.
+func WrapConnector(c driver.Connector) driver.Connector {
+	return tracedConnector{c}
+}
+
+// WrapDriver returns a driver.Driver whose connections are traced as described
+// by WrapConnector, for registering under a new name:
+//
+//	sql.Register("traced-postgres", hckit.WrapDriver(&pq.Driver{}))
+func WrapDriver(d driver.Driver) driver.Driver {
+	return tracedDriver{d}
+}
+
+// tracedDriver wraps a driver.Driver to trace its connections.
+type tracedDriver struct {
+	driver.Driver
+}
+
+// Open implements driver.Driver.
+func (d tracedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{conn}, nil
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d tracedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return tracedConnector{c}, nil
+	}
+
+	return dsnConnector{name, d}, nil
+}
+
+// dsnConnector is a driver.Connector for drivers that do not implement
+// driver.DriverContext.
+type dsnConnector struct {
+	name   string
+	driver tracedDriver
+}
+
+// Connect implements driver.Connector.
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.name)
+}
+
+// Driver implements driver.Connector.
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// tracedConnector wraps a driver.Connector to trace its connections.
+type tracedConnector struct {
+	driver.Connector
+}
+
+// Connect implements driver.Connector.
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedConn{conn}, nil
+}
+
+// Driver implements driver.Connector.
+func (c tracedConnector) Driver() driver.Driver {
+	return tracedDriver{c.Connector.Driver()}
+}
+
+// tracedConn wraps a driver.Conn to trace queries. It implements the optional
+// context-aware interfaces database/sql looks for, falling back to the plain
+// ones or returning driver.ErrSkip when the wrapped connection lacks them.
+type tracedConn struct {
+	driver.Conn
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := startSQLSpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	finishSQLSpan(span, err)
+
+	return rows, err
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	span := startSQLSpan(ctx, query)
+	res, err := execer.ExecContext(ctx, query, args)
+	finishSQLSpan(span, err)
+
+	return res, err
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedStmt{stmt, c.Conn, query}, nil
+}
+
+// Prepare implements driver.Conn.
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	// Mirror database/sql, which rejects options the driver cannot honor.
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+
+	return c.Conn.Begin()
+}
+
+// Ping implements driver.Pinger.
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+// IsValid implements driver.Validator.
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// tracedStmt wraps a prepared driver.Stmt to trace its executions.
+type tracedStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	span := startSQLSpan(ctx, s.query)
+
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	finishSQLSpan(span, err)
+
+	return rows, err
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	span := startSQLSpan(ctx, s.query)
+
+	var res driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args))
+	}
+	finishSQLSpan(span, err)
+
+	return res, err
+}
+
+// CheckNamedValue implements driver.NamedValueChecker, preferring the wrapped
+// statement's checker over the connection's.
+func (s *tracedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// ColumnConverter implements driver.ColumnConverter.
+func (s *tracedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+
+	return driver.DefaultParameterConverter
+}
+
+// namedValues converts args for drivers without context-aware statements.
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
+
+// startSQLSpan starts a span for query when ctx carries a span, and returns nil
+// otherwise.
+func startSQLSpan(ctx context.Context, query string) opentracing.Span {
+	if opentracing.SpanFromContext(ctx) == nil {
+		return nil
+	}
+
+	span, _ := StartDBSpan(ctx, sqlOperationName(query), query)
+	return span
+}
+
+// finishSQLSpan records err on span, if any, and finishes it.
+func finishSQLSpan(span opentracing.Span, err error) {
+	if span == nil {
+		return
+	}
+	defer span.Finish()
+
+	if err != nil && err != driver.ErrSkip {
+		ext.Error.Set(span, true)
+		span.LogFields(
+			otlog.String("event", "error"),
+			otlog.Error(err),
+		)
+	}
+}
+
+// sqlOperationName returns the span name for query, "SQL <OPERATION>" where
+// OPERATION is the statement's first keyword, e.g. "SQL SELECT".
+func sqlOperationName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+
+	return "SQL " + strings.ToUpper(fields[0])
+}
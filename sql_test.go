@@ -0,0 +1,428 @@
+package hckit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// errFakeQuery is returned by fakeConn and fakeStmt for the query "FAIL".
+var errFakeQuery = errors.New("fake query failed")
+
+// fakeDriver opens a fakeConn, without implementing driver.DriverContext.
+type fakeDriver struct {
+	conn driver.Conn
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return d.conn, nil
+}
+
+// fakeConnector connects to a fakeConn.
+type fakeConnector struct {
+	conn driver.Conn
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver(c)
+}
+
+// fakeConn implements only driver.Conn, recording the methods called on it and
+// its statements.
+type fakeConn struct {
+	calls []string
+	opts  driver.TxOptions
+}
+
+func (c *fakeConn) record(call string) {
+	c.calls = append(c.calls, call)
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.record("Prepare")
+	return &fakeStmt{c, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.record("Begin")
+	return fakeTx{}, nil
+}
+
+// newFakeConn returns a fakeConn as both the driver.Conn and its recorder.
+func newFakeConn() (driver.Conn, *fakeConn) {
+	c := &fakeConn{}
+	return c, c
+}
+
+// fakeContextConn adds the optional context-aware interfaces to fakeConn.
+type fakeContextConn struct {
+	fakeConn
+}
+
+func (c *fakeContextConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.record("QueryContext")
+	return fakeRows{}, fakeError(query)
+}
+
+func (c *fakeContextConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.record("ExecContext")
+	return driver.RowsAffected(1), fakeError(query)
+}
+
+func (c *fakeContextConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.record("PrepareContext")
+	return &fakeContextStmt{fakeStmt{&c.fakeConn, query}}, nil
+}
+
+func (c *fakeContextConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.record("BeginTx")
+	c.opts = opts
+	return fakeTx{}, nil
+}
+
+// newFakeContextConn returns a fakeContextConn and the fakeConn recording its
+// calls.
+func newFakeContextConn() (driver.Conn, *fakeConn) {
+	c := &fakeContextConn{}
+	return c, &c.fakeConn
+}
+
+// fakeStmt implements only driver.Stmt.
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.conn.record("Stmt.Exec")
+	return driver.RowsAffected(1), fakeError(s.query)
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.conn.record("Stmt.Query")
+	return fakeRows{}, fakeError(s.query)
+}
+
+// fakeContextStmt adds the optional context-aware interfaces and a
+// driver.ColumnConverter to fakeStmt.
+type fakeContextStmt struct {
+	fakeStmt
+}
+
+func (s *fakeContextStmt) ExecContext(context.Context, []driver.NamedValue) (driver.Result, error) {
+	s.conn.record("Stmt.ExecContext")
+	return driver.RowsAffected(1), fakeError(s.query)
+}
+
+func (s *fakeContextStmt) QueryContext(context.Context, []driver.NamedValue) (driver.Rows, error) {
+	s.conn.record("Stmt.QueryContext")
+	return fakeRows{}, fakeError(s.query)
+}
+
+func (s *fakeContextStmt) ColumnConverter(int) driver.ValueConverter {
+	return driver.Int32
+}
+
+// fakeRows is an empty result set.
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (fakeRows) Close() error {
+	return nil
+}
+
+func (fakeRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+// fakeTx is a transaction that does nothing.
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+// fakeError returns errFakeQuery for the query "FAIL".
+func fakeError(query string) error {
+	if query == "FAIL" {
+		return errFakeQuery
+	}
+
+	return nil
+}
+
+// withMockTracer registers a mocktracer as the GlobalTracer for the rest of the
+// test and returns it.
+func withMockTracer(t *testing.T) *mocktracer.MockTracer {
+	tracer := mocktracer.New()
+
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(old) })
+
+	return tracer
+}
+
+func TestWrapConnectorTracesQueries(t *testing.T) {
+	tests := []struct {
+		name      string
+		conn      func() (driver.Conn, *fakeConn)
+		wantCalls []string
+	}{
+		{
+			name:      "context-aware",
+			conn:      newFakeContextConn,
+			wantCalls: []string{"QueryContext", "ExecContext", "QueryContext"},
+		},
+		{
+			// database/sql falls back to preparing a statement when
+			// QueryContext and ExecContext return driver.ErrSkip.
+			name:      "plain",
+			conn:      newFakeConn,
+			wantCalls: []string{"Prepare", "Stmt.Query", "Prepare", "Stmt.Exec", "Prepare", "Stmt.Query"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := withMockTracer(t)
+			conn, fake := tt.conn()
+			db := sql.OpenDB(WrapConnector(fakeConnector{conn}))
+			defer db.Close()
+
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			rows, err := db.QueryContext(ctx, "select n from t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			if _, err := db.ExecContext(ctx, "UPDATE t SET n = ?", 1); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.QueryContext(ctx, "FAIL"); !errors.Is(err, errFakeQuery) {
+				t.Fatalf("QueryContext() error = %v, want %v", err, errFakeQuery)
+			}
+			parent.Finish()
+
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 4 {
+				t.Fatalf("got %d spans, want 4", len(spans))
+			}
+			want := []struct {
+				operation string
+				statement string
+				errored   bool
+			}{
+				{"SQL SELECT", "select n from t", false},
+				{"SQL UPDATE", "UPDATE t SET n = ?", false},
+				{"SQL FAIL", "FAIL", true},
+			}
+			for i, w := range want {
+				span := spans[i]
+				if span.OperationName != w.operation {
+					t.Errorf("span %d name = %q, want %q", i, span.OperationName, w.operation)
+				}
+				if got := span.ParentID; got != parent.(*mocktracer.MockSpan).SpanContext.SpanID {
+					t.Errorf("span %d parent = %d, want the parent span", i, got)
+				}
+				if got := span.Tag(string(ext.DBStatement)); got != w.statement {
+					t.Errorf("span %d db.statement = %v, want %q", i, got, w.statement)
+				}
+				if got := span.Tag(string(ext.SpanKind)); got != ext.SpanKindRPCClientEnum {
+					t.Errorf("span %d span.kind = %v, want client", i, got)
+				}
+				if got, _ := span.Tag(string(ext.Error)).(bool); got != w.errored {
+					t.Errorf("span %d error = %v, want %v", i, got, w.errored)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapConnectorSkipsQueriesWithoutSpan(t *testing.T) {
+	tracer := withMockTracer(t)
+	db := sql.OpenDB(WrapConnector(fakeConnector{&fakeContextConn{}}))
+	defer db.Close()
+
+	if _, err := db.ExecContext(context.Background(), "UPDATE t SET n = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := tracer.FinishedSpans(); len(spans) != 0 {
+		t.Errorf("got %d spans, want none", len(spans))
+	}
+}
+
+func TestWrapDriverTracesPreparedStatements(t *testing.T) {
+	tests := []struct {
+		name      string
+		conn      func() (driver.Conn, *fakeConn)
+		wantCalls []string
+	}{
+		{
+			name:      "context-aware",
+			conn:      newFakeContextConn,
+			wantCalls: []string{"PrepareContext", "Stmt.ExecContext", "Stmt.QueryContext"},
+		},
+		{
+			name:      "plain",
+			conn:      newFakeConn,
+			wantCalls: []string{"Prepare", "Stmt.Exec", "Stmt.Query"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := withMockTracer(t)
+			conn, fake := tt.conn()
+			connector, err := WrapDriver(fakeDriver{conn}).(driver.DriverContext).OpenConnector("fake")
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+
+			parent := tracer.StartSpan("parent")
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			stmt, err := db.PrepareContext(ctx, "DELETE FROM t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stmt.Close()
+			if _, err := stmt.ExecContext(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := stmt.QueryRowContext(ctx).Scan(new(int)); err != sql.ErrNoRows {
+				t.Fatalf("Scan() error = %v, want %v", err, sql.ErrNoRows)
+			}
+			parent.Finish()
+
+			if !reflect.DeepEqual(fake.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+
+			spans := tracer.FinishedSpans()
+			if len(spans) != 3 {
+				t.Fatalf("got %d spans, want 3", len(spans))
+			}
+			for i, span := range spans[:2] {
+				if span.OperationName != "SQL DELETE" {
+					t.Errorf("span %d name = %q, want %q", i, span.OperationName, "SQL DELETE")
+				}
+				if got := span.ParentID; got != parent.(*mocktracer.MockSpan).SpanContext.SpanID {
+					t.Errorf("span %d parent = %d, want the parent span", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTracedStmtColumnConverter(t *testing.T) {
+	plain := &tracedStmt{Stmt: &fakeStmt{}}
+	if got := plain.ColumnConverter(0); got != driver.DefaultParameterConverter {
+		t.Errorf("ColumnConverter() = %v, want driver.DefaultParameterConverter", got)
+	}
+
+	converting := &tracedStmt{Stmt: &fakeContextStmt{}}
+	if got := converting.ColumnConverter(0); got != driver.Int32 {
+		t.Errorf("ColumnConverter() = %v, want the statement's converter", got)
+	}
+}
+
+func TestTracedConnBeginTx(t *testing.T) {
+	tests := []struct {
+		name    string
+		conn    func() (driver.Conn, *fakeConn)
+		opts    *sql.TxOptions
+		wantErr bool
+		want    []string
+	}{
+		{
+			name: "plain default",
+			conn: newFakeConn,
+			want: []string{"Begin"},
+		},
+		{
+			name:    "plain isolation level",
+			conn:    newFakeConn,
+			opts:    &sql.TxOptions{Isolation: sql.LevelSerializable},
+			wantErr: true,
+		},
+		{
+			name:    "plain read-only",
+			conn:    newFakeConn,
+			opts:    &sql.TxOptions{ReadOnly: true},
+			wantErr: true,
+		},
+		{
+			name: "context-aware read-only",
+			conn: newFakeContextConn,
+			opts: &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true},
+			want: []string{"BeginTx"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, fake := tt.conn()
+			db := sql.OpenDB(WrapConnector(fakeConnector{conn}))
+			defer db.Close()
+
+			tx, err := db.BeginTx(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BeginTx() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				tx.Rollback()
+			}
+
+			if !reflect.DeepEqual(fake.calls, tt.want) {
+				t.Errorf("calls = %v, want %v", fake.calls, tt.want)
+			}
+			if tt.opts != nil && !tt.wantErr && !fake.opts.ReadOnly {
+				t.Errorf("BeginTx() options were not passed to the driver")
+			}
+		})
+	}
+}
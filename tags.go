@@ -0,0 +1,45 @@
+package hckit
+
+import (
+	"context"
+	"unicode/utf8"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// defaultMaxTagLength is the default limit, in bytes, on string tag values set
+// by the middleware and client spans.
+const defaultMaxTagLength = 1024
+
+// tagDeadlineExceeded marks spans whose request context passed its deadline.
+const tagDeadlineExceeded = "context.deadline_exceeded"
+
+// ellipsis marks a truncated tag value.
+const ellipsis = "..."
+
+// truncateTag shortens value to at most limit bytes, ending it with an ellipsis,
+// without splitting a UTF-8 sequence. A limit of zero or less disables
+// truncation.
+func truncateTag(value string, limit int) string {
+	if limit <= 0 || len(value) <= limit {
+		return value
+	}
+	if limit <= len(ellipsis) {
+		return ellipsis[:limit]
+	}
+
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+
+	return value[:cut] + ellipsis
+}
+
+// setDeadlineTag tags span with context.deadline_exceeded when ctx has passed
+// its deadline, separating timeouts from other failures.
+func setDeadlineTag(span opentracing.Span, ctx context.Context) {
+	if ctx.Err() == context.DeadlineExceeded {
+		span.SetTag(tagDeadlineExceeded, true)
+	}
+}
@@ -1,129 +1,500 @@
 package hckit
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
-	"net/http"
+	"math/rand"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
-	ext "github.com/opentracing/opentracing-go/ext"
-	otlog "github.com/opentracing/opentracing-go/log"
 	jaeger "github.com/uber/jaeger-client-go"
 	config "github.com/uber/jaeger-client-go/config"
-	jaegerlog "github.com/uber/jaeger-client-go/log"
-	"github.com/uber/jaeger-client-go/zipkin"
+	"github.com/uber/jaeger-client-go/rpcmetrics"
 	"github.com/uber/jaeger-lib/metrics"
 )
 
+// ErrEmptyServiceName is returned when a tracer is created without a service
+// name and JAEGER_SERVICE_NAME, or OTEL_SERVICE_NAME for
+// hckitotel.InitGlobalTracer, is not set.
+var ErrEmptyServiceName = errors.New("service name is empty")
+
 // InitGlobalTracer sets the GlobalTracer to an instance of Jaeger Tracer that
-// loads the Jaeger tracer from the environment, samples 100% of traces, and logs all spans to stdout.
-func InitGlobalTracer(service string) (io.Closer, error) {
-	//config from env
-	cfg, err := config.FromEnv()
+// loads the Jaeger tracer from the environment and logs all spans to stdout
+// unless JAEGER_REPORTER_LOG_SPANS is false. Options can be passed to override
+// these defaults. SERVICE_VERSION and DEPLOY_ENV, when set, are added to every
+// span as the service.version and deployment.environment tags.
+//
+// Only one global tracer is initialized at a time: later calls, including to
+// hckitotel.InitGlobalTracer, return the existing closer until it is closed.
+// Closing it more than once is safe.
+//
+// The sampler is chosen in order of precedence from WithSampler (or one of the
+// other sampler options), then the JAEGER_SAMPLER_* and JAEGER_SAMPLING_ENDPOINT
+// environment variables, and finally a const sampler that samples 100% of
+// traces.
+func InitGlobalTracer(service string, opts ...Option) (io.Closer, error) {
+	tc := newTracerConfig(opts...)
 
-	//overrides
-	cfg.Sampler = &config.SamplerConfig{
-		Type:  jaeger.SamplerTypeConst,
-		Param: 1,
+	return initGlobalTracer(tc, func() (opentracing.Tracer, io.Closer, Config, error) {
+		return newTracer(service, tc)
+	})
+}
+
+// InitGlobalTracerWithConfig sets the GlobalTracer to a Jaeger Tracer created
+// from cfg instead of the environment, for services that build their Jaeger
+// configuration elsewhere. The package's conventions are still applied: B3
+// propagation, or the propagators given as options, for HTTP headers and TextMap
+// carriers, and Zipkin-style shared RPC spans. Options override the
+// corresponding fields of cfg, and service, when not empty, replaces
+// cfg.ServiceName. cfg itself is not modified.
+func InitGlobalTracerWithConfig(cfg config.Configuration, service string, opts ...Option) (io.Closer, error) {
+	tc := newTracerConfig(opts...)
+
+	return initGlobalTracer(tc, func() (opentracing.Tracer, io.Closer, Config, error) {
+		return newTracerFromConfig(&cfg, service, tc)
+	})
+}
+
+// initGlobalTracer registers the tracer created by newTracer as the
+// GlobalTracer unless one is already registered, then applies the logging
+// options in tc.
+func initGlobalTracer(tc *tracerConfig, newTracer func() (opentracing.Tracer, io.Closer, Config, error)) (io.Closer, error) {
+	created := false
+	closer, err := RegisterGlobalTracer(func() (opentracing.Tracer, io.Closer, Config, error) {
+		created = true
+		return newTracer()
+	})
+	if err != nil || !created {
+		return closer, err
 	}
-	cfg.Reporter.LogSpans = true
 
-	jLogger := jaegerlog.StdLogger
-	jMetricsFactory := metrics.NullFactory
+	if tc.logger != nil {
+		SetLogger(tc.logger)
+	}
+	if tc.logLevel != nil {
+		SetLogLevel(*tc.logLevel)
+	}
 
-	// Zipkin shares span ID between client and server spans; it must be enabled via the following option.
-	zipkinPropagator := zipkin.NewZipkinB3HTTPHeaderPropagator()
+	return closer, nil
+}
 
-	// Create tracer and then initialize global tracer
-	closer, err := cfg.InitGlobalTracer(
-		service,
-		config.Logger(jLogger),
-		config.Metrics(jMetricsFactory),
-		config.Injector(opentracing.HTTPHeaders, zipkinPropagator),
-		config.Extractor(opentracing.HTTPHeaders, zipkinPropagator),
-		config.ZipkinSharedRPCSpan(true),
-	)
+// RegisterGlobalTracer sets the GlobalTracer to the tracer created by newTracer,
+// for packages providing other tracers such as hckitotel. As with
+// InitGlobalTracer, newTracer is not called while a global tracer is
+// registered, and the existing closer is returned instead. The Config returned
+// by newTracer is reported by EffectiveConfig, and the returned closer
+// unregisters the tracer once it has closed it.
+func RegisterGlobalTracer(newTracer func() (opentracing.Tracer, io.Closer, Config, error)) (io.Closer, error) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+
+	if globalCloser != nil {
+		logger().Warnf("Global tracer already initialized, reusing it")
+		return globalCloser, nil
+	}
 
+	tracer, closer, effective, err := newTracer()
 	if err != nil {
-		log.Printf("Could not initialize jaeger tracer: %s", err.Error())
 		return closer, err
 	}
 
-	return closer, nil
+	opentracing.SetGlobalTracer(tracer)
+	globalCloser = &onceCloser{Closer: closer}
+	globalConfig = effective
+
+	return globalCloser, nil
+}
+
+var (
+	// globalMu guards globalCloser.
+	globalMu sync.Mutex
+	// globalCloser closes the tracer registered by RegisterGlobalTracer, and
+	// is nil when none is registered.
+	globalCloser *onceCloser
+)
+
+// onceCloser closes the global tracer at most once, returning the first result
+// to every caller, and then allows it to be initialized again.
+type onceCloser struct {
+	io.Closer
+	once sync.Once
+	err  error
+}
+
+// Close implements io.Closer.
+func (c *onceCloser) Close() error {
+	return c.Shutdown(context.Background())
 }
 
-// TracingMiddleware returns an HTTP Handler appropriate for Middleware chaining via Router.Use.
-func TracingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Ignore health checks. TODO: this should be some sort of configured value
-		// in case a different endpoint name is used.
-		if strings.Contains(r.URL.Path, "health") {
-			next.ServeHTTP(w, r)
-			return
+// Shutdown closes the tracer as described by the package-level Shutdown.
+func (c *onceCloser) Shutdown(ctx context.Context) error {
+	c.once.Do(func() {
+		c.err = Shutdown(ctx, c.Closer)
+
+		globalMu.Lock()
+		if globalCloser == c {
+			globalCloser = nil
+			globalConfig = Config{}
 		}
+		globalMu.Unlock()
+	})
+
+	return c.err
+}
+
+// Shutdown closes c, flushing any buffered spans, but stops waiting and returns
+// an error wrapping ctx.Err() once ctx is done. Closers that provide their own
+// Shutdown(context.Context) error method, such as the one returned by
+// hckitotel.InitGlobalTracer, are shut down with ctx directly.
+func Shutdown(ctx context.Context, c io.Closer) error {
+	if s, ok := c.(interface{ Shutdown(context.Context) error }); ok {
+		return s.Shutdown(ctx)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("tracer did not flush before shutdown: %w", ctx.Err())
+	}
+}
+
+// NewTracer creates a Jaeger Tracer configured the same way as InitGlobalTracer
+// without registering it as the GlobalTracer.
+func NewTracer(service string, opts ...Option) (opentracing.Tracer, io.Closer, error) {
+	tracer, closer, _, err := newTracer(service, newTracerConfig(opts...))
+	return tracer, closer, err
+}
+
+// newTracer creates a Jaeger Tracer from the environment with tc applied, and
+// returns it with the configuration applied.
+func newTracer(service string, tc *tracerConfig) (opentracing.Tracer, io.Closer, Config, error) {
+	if service == "" && os.Getenv("JAEGER_SERVICE_NAME") == "" {
+		return nil, nil, Config{}, ErrEmptyServiceName
+	}
+
+	//config from env
+	cfg, err := config.FromEnv()
+	if err != nil {
+		tc.tracerLogger().Errorf("Could not load jaeger config from environment: %s", err.Error())
+		return nil, nil, Config{}, err
+	}
+
+	//defaults
+	if tc.sampler == nil && !samplerFromEnv() {
+		cfg.Sampler = &config.SamplerConfig{
+			Type:  jaeger.SamplerTypeConst,
+			Param: 1,
+		}
+	}
+	// Log spans unless the operator has said otherwise.
+	if os.Getenv("JAEGER_REPORTER_LOG_SPANS") == "" {
+		cfg.Reporter.LogSpans = true
+	}
+
+	return newTracerFromConfig(cfg, service, tc)
+}
+
+// newTracerFromConfig creates a Jaeger Tracer from cfg with tc applied,
+// without modifying the sampler and reporter configurations cfg points to, and
+// returns it with the configuration applied.
+func newTracerFromConfig(cfg *config.Configuration, service string, tc *tracerConfig) (opentracing.Tracer, io.Closer, Config, error) {
+	l := tc.tracerLogger()
+
+	//overrides
+	if service != "" {
+		cfg.ServiceName = service
+	}
+	if cfg.ServiceName == "" {
+		return nil, nil, Config{}, ErrEmptyServiceName
+	}
+	if tc.sampler != nil {
+		sampler := *tc.sampler
+		cfg.Sampler = &sampler
+	} else if cfg.Sampler != nil {
+		sampler := *cfg.Sampler
+		cfg.Sampler = &sampler
+	} else {
+		// Match the Jaeger client's default of a remotely controlled sampler.
+		cfg.Sampler = &config.SamplerConfig{Type: jaeger.SamplerTypeRemote}
+	}
+	if isRemoteSampler(cfg.Sampler) && cfg.Sampler.Param == 0 {
+		cfg.Sampler.Param = defaultSamplingProbability
+	}
+	if cfg.Reporter != nil {
+		reporter := *cfg.Reporter
+		cfg.Reporter = &reporter
+	} else {
+		cfg.Reporter = &config.ReporterConfig{}
+	}
+	if tc.logSpans != nil {
+		cfg.Reporter.LogSpans = *tc.logSpans
+	}
+	if tc.disabled != nil {
+		cfg.Disabled = *tc.disabled
+	}
+	if tc.agentHostPort != "" {
+		cfg.Reporter.LocalAgentHostPort = tc.agentHostPort
+	}
+	if tc.collectorEndpoint != "" {
+		cfg.Reporter.CollectorEndpoint = tc.collectorEndpoint
+	}
+	if tc.collectorUser != "" {
+		cfg.Reporter.User = tc.collectorUser
+		cfg.Reporter.Password = tc.collectorPassword
+	}
+	if tc.queueSize > 0 {
+		cfg.Reporter.QueueSize = tc.queueSize
+	}
+	if tc.flushInterval > 0 {
+		cfg.Reporter.BufferFlushInterval = tc.flushInterval
+	}
+	cfg.Tags = mergeTags(mergeTags(cfg.Tags, tc.envTags()), tc.tags)
 
-		log.Printf("INFO: TracingMiddleware beginning for %s---------------------------", r.URL.Path)
+	jLogger := jaegerLogger{l}
+	jMetricsFactory := tc.metricsFactory
 
-		tracer := opentracing.GlobalTracer()
-		// If no context exists an error will be returned, but we ignore it
-		// because if ctx == nil, a root span will be created.
-		wireContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	// Zipkin shares span ID between client and server spans; it must be enabled via the ZipkinSharedRPCSpan option.
+	// It is on unless disabled with WithSharedRPCSpan.
+	options := []config.Option{
+		config.Logger(jLogger),
+		config.Metrics(jMetricsFactory),
+		config.Injector(opentracing.HTTPHeaders, multiInjector(tc.injectors)),
+		config.Extractor(opentracing.HTTPHeaders, chainedExtractor(tc.extractors)),
+		config.Injector(opentracing.TextMap, multiInjector(tc.injectors)),
+		config.Extractor(opentracing.TextMap, chainedExtractor(tc.extractors)),
+		config.ZipkinSharedRPCSpan(tc.sharedRPCSpan),
+		config.Gen128Bit(tc.gen128Bit),
+		config.MaxTagValueLength(tc.maxTagValueLength),
+	}
+
+	var sampler jaeger.Sampler
+	if len(tc.operationRates) > 0 && !cfg.Disabled {
+		var err error
+		sampler, err = newSampler(cfg, tc)
 		if err != nil {
-			log.Printf("WARN: Extract failed, error recieved.\n%v\n", err)
+			l.Errorf("Could not initialize jaeger sampler: %s", err.Error())
+			return nil, nil, Config{}, err
 		}
+		options = append(options, config.Sampler(sampler))
+	}
 
-		if wireContext != nil {
-			log.Printf("INFO: WireContext is %v", wireContext)
+	var reporter jaeger.Reporter
+	if tc.spanFile != "" && !cfg.Disabled {
+		var err error
+		reporter, err = newFileReporter(cfg, tc, jLogger)
+		if err != nil {
+			l.Errorf("Could not initialize span file reporter: %s", err.Error())
+			return nil, nil, Config{}, err
 		}
-		span := tracer.StartSpan(r.URL.Path, ext.RPCServerOption(wireContext))
-		defer span.Finish()
+		options = append(options, config.Reporter(reporter))
+	}
 
-		span.LogFields(
-			otlog.String("event", r.URL.Path),
-			otlog.String("value", "start"),
-		)
+	// Create tracer
+	var tracer opentracing.Tracer
+	var closer io.Closer
+	var err error
+	if tc.xrayTraceIDs && !cfg.Disabled {
+		tracer, closer, err = newXRayTracer(cfg, tc, jLogger, sampler, reporter)
+	} else {
+		tracer, closer, err = cfg.NewTracer(options...)
+	}
 
-		next.ServeHTTP(w, r)
+	if err != nil {
+		l.Errorf("Could not initialize jaeger tracer: %s", err.Error())
+		return tracer, closer, Config{}, err
+	}
 
-		span.LogFields(
-			otlog.String("event", r.URL.Path),
-			otlog.String("value", "finish"),
-		)
+	return tracer, closer, effectiveConfig(cfg, tc), nil
+}
 
-		log.Print("INFO: TracingMiddleware complete----------------------------------------------")
+// newXRayTracer creates a Jaeger Tracer from cfg as cfg.NewTracer does, but
+// with trace IDs that X-Ray accepts, which the config package cannot set up.
+// sampler and reporter, when not nil, replace those described by cfg. Baggage
+// restrictions and debug throttling are not supported, as the Jaeger client
+// keeps their implementations internal.
+func newXRayTracer(cfg *config.Configuration, tc *tracerConfig, logger jaeger.Logger, sampler jaeger.Sampler, reporter jaeger.Reporter) (opentracing.Tracer, io.Closer, error) {
+	if cfg.BaggageRestrictions != nil || cfg.Throttler != nil {
+		return nil, nil, errors.New("baggage restrictions and throttling are not supported with X-Ray trace IDs")
+	}
 
-		return
-	})
+	jMetrics := jaeger.NewMetrics(tc.metricsFactory, nil)
+	if sampler == nil {
+		var err error
+		if sampler, err = cfg.Sampler.NewSampler(cfg.ServiceName, jMetrics); err != nil {
+			return nil, nil, err
+		}
+	}
+	if reporter == nil {
+		var err error
+		if reporter, err = cfg.Reporter.NewReporter(cfg.ServiceName, jMetrics, logger); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	options := []jaeger.TracerOption{
+		jaeger.TracerOptions.Metrics(jMetrics),
+		jaeger.TracerOptions.Logger(logger),
+		jaeger.TracerOptions.CustomHeaderKeys(cfg.Headers),
+		jaeger.TracerOptions.Gen128Bit(true),
+		jaeger.TracerOptions.HighTraceIDGenerator(xrayHighTraceID),
+		jaeger.TracerOptions.ZipkinSharedRPCSpan(tc.sharedRPCSpan),
+		jaeger.TracerOptions.MaxTagValueLength(tc.maxTagValueLength),
+		jaeger.TracerOptions.Injector(opentracing.HTTPHeaders, multiInjector(tc.injectors)),
+		jaeger.TracerOptions.Extractor(opentracing.HTTPHeaders, chainedExtractor(tc.extractors)),
+		jaeger.TracerOptions.Injector(opentracing.TextMap, multiInjector(tc.injectors)),
+		jaeger.TracerOptions.Extractor(opentracing.TextMap, chainedExtractor(tc.extractors)),
+	}
+	for _, tag := range cfg.Tags {
+		options = append(options, jaeger.TracerOptions.Tag(tag.Key, tag.Value))
+	}
+	if cfg.RPCMetrics {
+		options = append(options, jaeger.TracerOptions.Observer(rpcmetrics.NewObserver(
+			tc.metricsFactory.Namespace(metrics.NSOptions{Name: "jaeger-rpc", Tags: map[string]string{"component": "jaeger"}}),
+			rpcmetrics.DefaultNameNormalizer,
+		)))
+	}
+
+	tracer, closer := jaeger.NewTracer(cfg.ServiceName, sampler, reporter, options...)
+
+	return tracer, closer, nil
 }
 
-// InjectHeaders injects the necessary opentracing headers to support
-// distributed tracing.
-func InjectHeaders(r *http.Request) {
-	span := opentracing.GlobalTracer().StartSpan(r.URL.Path)
-	defer span.Finish()
+// defaultSamplingProbability is the probability the Jaeger client's remote
+// sampler samples at until it first hears from the agent.
+const defaultSamplingProbability = 0.001
 
-	log.Printf("INFO: span.Context is %v", span.Context())
+// isRemoteSampler reports whether sc configures a remotely controlled sampler,
+// which the Jaeger client also creates when no type is set.
+func isRemoteSampler(sc *config.SamplerConfig) bool {
+	return sc.Type == "" || strings.ToLower(sc.Type) == jaeger.SamplerTypeRemote
+}
 
-	ext.SpanKindRPCClient.Set(span)
-	ext.HTTPUrl.Set(span, r.URL.Path)
-	ext.HTTPMethod.Set(span, r.Method)
-	span.Tracer().Inject(
-		span.Context(),
-		opentracing.HTTPHeaders,
-		opentracing.HTTPHeadersCarrier(r.Header),
-	)
+// xrayHighTraceID returns the high 64 bits of an X-Ray trace ID: the current
+// time in seconds followed by 32 random bits.
+func xrayHighTraceID() uint64 {
+	return uint64(time.Now().Unix())<<32 | uint64(rand.Uint32())
 }
 
-// TracingRoundTripper implements the http.RoundTripper interface
-type TracingRoundTripper struct {
-	Proxied http.RoundTripper
+// tracerLogger returns the logger the tracer logs to.
+func (tc *tracerConfig) tracerLogger() tracerLogger {
+	return tracerLogger{tc.logger, tc.logLevel}
 }
 
-// RoundTrip injects tracing headers to outbound request.
-// TODO: Find a way to make registration less manual.
-func (trt TracingRoundTripper) RoundTrip(req *http.Request) (res *http.Response, e error) {
-	log.Print("INFO: TracingRoundTripper.RountTrip injecting headers")
-	InjectHeaders(req)
-	return trt.Proxied.RoundTrip(req)
+// newFileReporter creates a reporter writing spans to tc.spanFile in addition
+// to the reporter configured in cfg.
+func newFileReporter(cfg *config.Configuration, tc *tracerConfig, logger jaeger.Logger) (jaeger.Reporter, error) {
+	metrics := jaeger.NewMetrics(tc.metricsFactory, nil)
+	reporter, err := cfg.Reporter.NewReporter(cfg.ServiceName, metrics, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newFileTransport(cfg.ServiceName, tc.spanFile)
+	if err != nil {
+		reporter.Close()
+		return nil, err
+	}
+
+	return jaeger.NewCompositeReporter(reporter, jaeger.NewRemoteReporter(
+		transport,
+		jaeger.ReporterOptions.BufferFlushInterval(cfg.Reporter.BufferFlushInterval),
+		jaeger.ReporterOptions.Logger(logger),
+	)), nil
+}
+
+// newSampler creates a sampler applying the per-operation rates in tc, falling
+// back to the sampler configured in cfg.
+func newSampler(cfg *config.Configuration, tc *tracerConfig) (jaeger.Sampler, error) {
+	fallback, err := cfg.Sampler.NewSampler(cfg.ServiceName, jaeger.NewMetrics(tc.metricsFactory, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := newOperationSampler(tc.operationRates, fallback)
+	if err != nil {
+		fallback.Close()
+		return nil, err
+	}
+
+	return sampler, nil
+}
+
+// mergeTags returns tags with extra added in key order, replacing any existing
+// tags with the same key.
+func mergeTags(tags []opentracing.Tag, extra map[string]string) []opentracing.Tag {
+	if len(extra) == 0 {
+		return tags
+	}
+
+	merged := make([]opentracing.Tag, 0, len(tags)+len(extra))
+	for _, tag := range tags {
+		if _, ok := extra[tag.Key]; !ok {
+			merged = append(merged, tag)
+		}
+	}
+
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		merged = append(merged, opentracing.Tag{Key: k, Value: extra[k]})
+	}
+
+	return merged
+}
+
+// Process tags read from the environment variables named by WithVersionEnv and
+// WithEnvironmentEnv.
+const (
+	tagServiceVersion        = "service.version"
+	tagDeploymentEnvironment = "deployment.environment"
+)
+
+// envTags returns the process tags set from the version and environment
+// variables.
+func (tc *tracerConfig) envTags() map[string]string {
+	tags := make(map[string]string, 2)
+	for key, name := range map[string]string{
+		tagServiceVersion:        tc.versionEnv,
+		tagDeploymentEnvironment: tc.environmentEnv,
+	} {
+		if name == "" {
+			continue
+		}
+		if value := os.Getenv(name); value != "" {
+			tags[key] = value
+		}
+	}
+
+	return tags
+}
+
+// samplerFromEnv reports whether the sampler was configured via the environment.
+func samplerFromEnv() bool {
+	for _, key := range []string{
+		"JAEGER_SAMPLER_TYPE",
+		"JAEGER_SAMPLER_PARAM",
+		"JAEGER_SAMPLING_ENDPOINT",
+		"JAEGER_SAMPLER_MANAGER_HOST_PORT",
+	} {
+		if os.Getenv(key) != "" {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,90 @@
+package hckit
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redacted replaces sensitive values in span tags.
+const redacted = "[REDACTED]"
+
+// queryPolicy controls how a query string is rendered in a URL span tag. The
+// zero value drops the query entirely.
+type queryPolicy struct {
+	include bool
+	// redact holds lower-cased keys whose values are replaced with redacted.
+	redact map[string]struct{}
+	// allow is nil unless only some keys are kept, in which case it holds
+	// the lower-cased keys and every other pair is dropped.
+	allow map[string]struct{}
+}
+
+// includeQuery includes the query string, redacting the values of sensitive
+// keys.
+func (q *queryPolicy) includeQuery(sensitive []string) {
+	q.include = true
+	q.redact = keySet(sensitive)
+}
+
+// allowQuery includes only the allowed keys of the query string.
+func (q *queryPolicy) allowQuery(allowed []string) {
+	q.include = true
+	q.allow = keySet(allowed)
+}
+
+// keySet returns the lower-cased keys as a set.
+func keySet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+	}
+
+	return set
+}
+
+// urlTag formats u for the http.url tag according to the policy. User info is
+// always removed.
+func (q queryPolicy) urlTag(u *url.URL) string {
+	tagged := *u
+	tagged.User = nil
+	tagged.RawQuery = q.query(u.RawQuery)
+	tagged.ForceQuery = false
+
+	return tagged.String()
+}
+
+// query returns rawQuery with sensitive values redacted and keys that are not
+// allowed dropped, preserving the original order and encoding of every other
+// pair.
+func (q queryPolicy) query(rawQuery string) string {
+	if !q.include || rawQuery == "" {
+		return ""
+	}
+	if len(q.redact) == 0 && q.allow == nil {
+		return rawQuery
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		rawKey := pair
+		if j := strings.Index(pair, "="); j >= 0 {
+			rawKey = pair[:j]
+		}
+
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		key = strings.ToLower(key)
+		if _, ok := q.allow[key]; q.allow != nil && !ok {
+			continue
+		}
+		if _, ok := q.redact[key]; ok {
+			pair = rawKey + "=" + redacted
+		}
+		kept = append(kept, pair)
+	}
+
+	return strings.Join(kept, "&")
+}